@@ -7,18 +7,48 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
 
 var version = "dev"
 
-const defaultMaxFileSize = 1024 * 1024 // 1MB
+var (
+	errUnknownFormat          = errors.New("unknown output format")
+	errConflictingExtensions  = errors.New("extension is both included and excluded")
+	errInvalidMaxFileSize     = errors.New("maximum file size must be positive")
+	errNegativeWrapWidth      = errors.New("wrap width must not be negative")
+	errNegativeReadThroughput = errors.New("read throughput limit must not be negative")
+	errIgnoreFileNotFound     = errors.New("ignore file not found")
+	errNegativeMaxMemory      = errors.New("memory cap must not be negative")
+	errNegativeMaxFileTokens  = errors.New("maximum file tokens must not be negative")
+	errNegativeMaxFiles       = errors.New("maximum files must not be negative")
+	errOutputDirOverlap       = errors.New("output directory overlaps the input directory")
+	errNoFiles                = errors.New("no files matched the current configuration")
+	errConflictingSince       = errors.New("--since and --since-tag cannot be combined")
+	errSinceRefAsDirectory    = errors.New("--since takes its ref after an equals sign")
+	errSplitFormat            = errors.New("--split-size and --split-by-dir only support the markdown format")
+	errConflictingSplit       = errors.New("--split-size and --split-by-dir cannot be combined")
+	errConflictingTests       = errors.New("--exclude-tests and --tests-only cannot be combined")
+	errUnknownFlagSetting     = errors.New("flag has no configuration setting")
+	errUnknownSort            = errors.New("unknown sort value")
+	errSummarizeTemplate      = errors.New("--summarize cannot be combined with --template")
+	errSummarizeFormat        = errors.New("--summarize only supports the markdown format")
+)
 
 func Execute() error {
 	cfg, err := config.Load()
@@ -53,88 +83,757 @@ func Execute() error {
 }
 
 func createRootCommand(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	// Flags are bound to the built-in defaults, and only those given explicitly override
+	// the values that cfg resolved from the profile and environment.
+	flags := config.NewConfig()
+
 	rootCmd := &cobra.Command{
 		Use:   "code2md [directory]",
 		Short: "Convert source code repository to markdown for LLM consumption",
 		Long: `A CLI tool that gathers all source code files from a repository
 and converts them into a single markdown file suitable for feeding to Large Language Models.`,
 		Args: cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return applyChangedFlags(cmd, flags, cfg)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.PrintConfig {
+				return printConfig(cmd, cfg)
+			}
+
+			if cfg.GenerateProfile {
+				return generateProfile(cmd, cfg)
+			}
+
+			if cfg.JSONSchema {
+				return printJSONSchema(cmd, cfg)
+			}
+
 			return runCode2MD(cmd.Context(), cfg, logger, args)
 		},
 	}
 
 	rootCmd.Version = version
+	rootCmd.AddCommand(newIgnoreCheckCommand(cfg, flags, logger))
+
+	rootCmd.Flags().StringVarP(&flags.OutputFile, "output", "o", flags.OutputFile,
+		"Output markdown file, with optional {date}, {time}, {repo} and {count} placeholders (defaults to <repository name>.md)")
+	rootCmd.Flags().StringVar(&flags.OutputDir, "output-dir", flags.OutputDir,
+		"Write each gathered file to <output-dir>/<relative-path>.md instead of a single output file")
+	rootCmd.Flags().StringVar(&flags.RepoName, "repo-name", flags.RepoName,
+		"Repository name for the header and default output file (inferred from the git remote or directory name)")
+	rootCmd.Flags().StringSliceVar(&flags.Formats, "format", flags.Formats,
+		"Output formats: markdown, json, jsonl, llm-chunks, or a plugin format (several formats use --output as the base name)")
+	rootCmd.Flags().StringSliceVar(&flags.Plugins, "plugin", flags.Plugins,
+		"Go plugin (.so) providing a custom output format (repeatable)")
+	rootCmd.Flags().IntVar(&flags.ChunkTokens, "chunk-tokens", flags.ChunkTokens,
+		"Approximate tokens per chunk for --format llm-chunks")
+	rootCmd.Flags().StringSliceVarP(&flags.IncludeExt, "include", "i", flags.IncludeExt, "File extensions to include (e.g., .go,.py)")
+	rootCmd.Flags().StringSliceVarP(&flags.ExcludeExt, "exclude", "e", flags.ExcludeExt, "File extensions to exclude")
+	rootCmd.Flags().StringSliceVar(&flags.Languages, "languages", flags.Languages,
+		"Only include files whose detected language is in this list (e.g., go,python)")
+	rootCmd.Flags().StringSliceVar(&flags.ExcludePatterns, "exclude-patterns", flags.ExcludePatterns,
+		"Glob patterns of files to exclude (e.g., *.pb.go,docs/**)")
+	rootCmd.Flags().BoolVar(&flags.ExcludeGenerated, "exclude-generated", flags.ExcludeGenerated,
+		"Exclude common generated files (e.g., *.pb.go, *_gen.go, *.min.js)")
+	rootCmd.Flags().BoolVar(&flags.ExcludeTests, "exclude-tests", flags.ExcludeTests,
+		"Exclude test files by language conventions (e.g., *_test.go, test_*.py, *.spec.ts)")
+	rootCmd.Flags().BoolVar(&flags.TestsOnly, "tests-only", flags.TestsOnly, "Only include test files (the opposite of --exclude-tests)")
+	rootCmd.Flags().StringSliceVarP(&flags.ExcludeDirs, "exclude-dirs", "d", flags.ExcludeDirs, "Directories to exclude")
+	rootCmd.Flags().BoolVar(&flags.NoDefaultExcludes, "no-default-excludes", flags.NoDefaultExcludes,
+		"Disable the built-in extension, file, and directory lists (you will get many non-source files without explicit --include)")
+	rootCmd.Flags().BoolVar(&flags.NoDefaultExcludeDirs, "no-default-exclude-dirs", flags.NoDefaultExcludeDirs,
+		"Disable only the built-in directory list (vendor, dist, ...), so just --exclude-dirs and ignore files apply")
+	rootCmd.Flags().BoolVar(&flags.KeepLockfiles, "keep-lockfiles", flags.KeepLockfiles,
+		"Include package manager lockfiles (package-lock.json, yarn.lock, Cargo.lock, ...)")
+	rootCmd.Flags().StringSliceVar(&flags.PriorityFiles, "priority-files", flags.PriorityFiles,
+		"File names always gathered whatever their extension, up to 10MB, and placed first (e.g. go.mod, package.json)")
+	rootCmd.Flags().StringVar(&flags.SinceTag, "since-tag", flags.SinceTag, "Only include files changed between this git tag and HEAD")
+	rootCmd.Flags().StringVar(&flags.Since, "since", flags.Since,
+		"Only include files changed on HEAD since it branched off this ref (--since=<ref>); a bare --since uses the default branch")
+	rootCmd.Flags().Lookup("since").NoOptDefVal = config.SinceDefaultBranch
+	rootCmd.Flags().StringVar(&flags.SinceOutput, "since-output", flags.SinceOutput,
+		"Only include files without a section in this previous markdown output")
+	rootCmd.Flags().StringVar(&flags.StdinFile, "stdin-file", flags.StdinFile,
+		"Read stdin as a file of this language or extension (e.g., go) and put it first in the output")
+	rootCmd.Flags().BoolVar(&flags.RelativizeSymlinks, "relativize-symlinks", flags.RelativizeSymlinks,
+		"Note when gathered files are symlinks to the same underlying file")
+	rootCmd.Flags().StringVar(&flags.RelativeTo, "relative-to", flags.RelativeTo,
+		"Directory that file paths in the output are relative to (default: the scanned directory)")
+	rootCmd.Flags().StringVar(&flags.StripPrefix, "strip-prefix", flags.StripPrefix,
+		"Literal prefix removed from the start of every file path in the output")
+	rootCmd.Flags().BoolVar(&flags.NoGitInfoExclude, "no-git-info-exclude", flags.NoGitInfoExclude,
+		"Do not apply the repository-local ignore patterns in .git/info/exclude")
+	rootCmd.Flags().BoolVar(&flags.NpmIgnore, "npm-ignore", flags.NpmIgnore, "Also apply patterns from .npmignore")
+	rootCmd.Flags().StringSliceVar(&flags.IgnoreFiles, "ignore-file", flags.IgnoreFiles,
+		"Gitignore-syntax file whose patterns exclude files, e.g. .eslintignore (repeatable)")
+	rootCmd.Flags().BoolVar(&flags.ShowIgnoreRules, "show-ignore-rules", flags.ShowIgnoreRules,
+		"Append a section listing the loaded ignore files and their patterns")
+	rootCmd.Flags().BoolVar(&flags.NpmOnly, "npm-only", flags.NpmOnly,
+		"Only include files listed in the \"files\" field of package.json")
+	rootCmd.Flags().Int64VarP(&flags.MaxFileSize, "max-size", "s", flags.MaxFileSize, "Maximum file size in bytes")
+	rootCmd.Flags().IntVar(&flags.MaxFileTokens, "max-file-tokens", flags.MaxFileTokens,
+		"Skip files whose estimated token count exceeds this (0 for no limit)")
+	rootCmd.Flags().IntVar(&flags.MaxFiles, "max-files", flags.MaxFiles,
+		"Stop walking after this many candidate files, in walk order; files later skipped by filters count too (0 for no limit)")
+	rootCmd.Flags().BoolVar(&flags.SkipWhitespaceOnly, "skip-whitespace-only", flags.SkipWhitespaceOnly,
+		"Skip files that contain only whitespace (empty files are kept)")
+	rootCmd.Flags().BoolVar(&flags.FailOnLargeFile, "fail-on-large-file", flags.FailOnLargeFile,
+		"Fail the run, listing the offending files, instead of skipping files larger than --max-size")
+	rootCmd.Flags().Int64Var(&flags.MaxReadBytesPerSec, "max-read-bytes-per-sec", flags.MaxReadBytesPerSec,
+		"Throttle file reads to this many bytes per second across all workers (0 means unlimited)")
+	rootCmd.Flags().Int64Var(&flags.MaxMemory, "max-memory", flags.MaxMemory,
+		"Soft heap cap in bytes: pause new file reads while heap usage is above it (0 means unlimited)")
+	rootCmd.Flags().BoolVarP(&flags.IncludeHidden, "hidden", "H", flags.IncludeHidden, "Include hidden files and directories")
+	rootCmd.Flags().BoolVar(&flags.SkipHiddenDirs, "skip-hidden-dirs", flags.SkipHiddenDirs,
+		"Prune hidden directories even when --hidden includes hidden files (use --skip-hidden-dirs=false to walk them)")
+	rootCmd.Flags().BoolVarP(&flags.Verbose, "verbose", "v", flags.Verbose, "Verbose output")
+	rootCmd.Flags().StringSliceVar(&flags.NoContentFor, "no-content-for", flags.NoContentFor,
+		"Glob patterns of files to list without their content (e.g., secrets/*,*.lock)")
+	rootCmd.Flags().StringSliceVar(&flags.Summarize, "summarize", flags.Summarize,
+		"Glob patterns of files whose content is replaced by a summary: Go declarations, or the first and last 10 lines")
+	rootCmd.Flags().BoolVar(&flags.NoHeader, "no-header", flags.NoHeader, "Omit the header section with repository and size details")
+	rootCmd.Flags().BoolVar(&flags.Chart, "chart", flags.Chart, "Add an ASCII bar chart of the top languages by size after the header")
+	rootCmd.Flags().BoolVar(&flags.RelativeSizeBar, "relative-size-bar", flags.RelativeSizeBar,
+		"Add an ASCII bar to each file section showing its size relative to the largest file")
+	rootCmd.Flags().BoolVar(&flags.AnnotateImports, "annotate-imports", flags.AnnotateImports,
+		"Add an HTML comment listing each file's imports above its code block (Go is parsed; other languages are scanned)")
+	rootCmd.Flags().BoolVar(&flags.NoTOC, "no-toc", flags.NoTOC, "Omit the table of contents")
+	rootCmd.Flags().BoolVar(&flags.TOCTable, "toc-table", flags.TOCTable,
+		"Write the table of contents as a table with each file's size, line count and language")
+	rootCmd.Flags().BoolVar(&flags.TOCHierarchy, "toc-hierarchy", flags.TOCHierarchy,
+		"Write the table of contents as a nested list mirroring the directory tree")
+	rootCmd.Flags().StringVar(&flags.GroupBy, "group-by", flags.GroupBy,
+		"Group file sections under a heading per directory (supported: directory, top-level)")
+	rootCmd.Flags().BoolVar(&flags.GroupByDir, "group-by-dir", flags.GroupByDir,
+		"Group file sections by top-level directory; shorthand for --group-by top-level")
+	rootCmd.Flags().StringVar(&flags.Sort, "sort", flags.Sort,
+		"Order of the files (supported: path, language); language keeps files of a language together, by path, without headings")
+	rootCmd.Flags().BoolVar(&flags.IncludeDirReadmeContext, "include-dir-readme-context", flags.IncludeDirReadmeContext,
+		"When grouping, put the README.md of each group's directory first in its group")
+	rootCmd.Flags().IntVar(&flags.MaxFilesPerDir, "max-files-per-dir", flags.MaxFilesPerDir,
+		"Include at most this many files per directory (alphabetically first) and note how many were omitted (0 for no limit)")
+	rootCmd.Flags().Int64Var(&flags.SplitSize, "split-size", flags.SplitSize,
+		"Split the markdown into parts of at most this many bytes of file content, with an <output>-index.md (0 to disable)")
+	rootCmd.Flags().Int64Var(&flags.WarnOutputSize, "warn-output-size", flags.WarnOutputSize,
+		"Warn on stderr when an output file is larger than this many bytes (0 to disable)")
+	rootCmd.Flags().BoolVar(&flags.SplitByDir, "split-by-dir", flags.SplitByDir,
+		"Write one markdown per top-level directory (e.g. cmd.md), with root files in the output file")
+	rootCmd.Flags().BoolVar(&flags.RelativeAnchorIDs, "relative-anchor-ids", flags.RelativeAnchorIDs,
+		"Link the table of contents to short numeric anchors (file-1, file-2, ...) instead of path-based ones")
+	rootCmd.Flags().StringVar(&flags.SeedPrompt, "seed-prompt", flags.SeedPrompt,
+		"Open the output with an LLM instruction: a preset (review, explain, document, find-bugs) or a file path")
+	rootCmd.Flags().StringVar(&flags.ContentPrefix, "content-prefix", flags.ContentPrefix,
+		"Text written at the start of the markdown, before the header")
+	rootCmd.Flags().StringVar(&flags.ContentPrefixFile, "content-prefix-file", flags.ContentPrefixFile,
+		"File whose contents are used as --content-prefix")
+	rootCmd.Flags().StringVar(&flags.ContentSuffix, "content-suffix", flags.ContentSuffix,
+		"Text written at the end of the markdown, after all file contents")
+	rootCmd.Flags().StringVar(&flags.ContentSuffixFile, "content-suffix-file", flags.ContentSuffixFile,
+		"File whose contents are used as --content-suffix")
+	rootCmd.Flags().StringVar(&flags.Grep, "grep", flags.Grep,
+		"Include only lines matching this regex, with line numbers, and omit files with no matches")
+	rootCmd.Flags().BoolVar(&flags.PreserveNoFinalNewline, "preserve-no-final-newline", flags.PreserveNoFinalNewline,
+		"Note files that do not end with a newline instead of silently adding one")
+	rootCmd.Flags().StringVar(&flags.Template, "template", flags.Template,
+		"Go text/template file used to render the markdown output instead of the built-in layout")
+	rootCmd.Flags().StringToStringVar(&flags.TemplateVars, "template-var", flags.TemplateVars,
+		"Variable available to --template as {{ .Vars.KEY }}, given as KEY=VALUE (repeatable)")
+	rootCmd.Flags().IntVar(&flags.WrapWidth, "wrap-width", flags.WrapWidth,
+		"Hard-wrap prose files (.md, .txt, .rst) to this many columns (0 disables)")
+	rootCmd.Flags().BoolVar(&flags.TrimTrailingWhitespace, "trim-trailing-whitespace", flags.TrimTrailingWhitespace,
+		"Remove trailing spaces and tabs from every line of file content")
+	rootCmd.Flags().BoolVar(&flags.StripBlankLines, "strip-blank-lines", flags.StripBlankLines,
+		"Collapse runs of blank lines in file content into a single blank line")
+	rootCmd.Flags().StringVar(&flags.OutputEncoding, "output-encoding", flags.OutputEncoding,
+		"Output encoding: utf8 or utf8-bom (utf8-bom may break some Markdown renderers)")
+	rootCmd.Flags().BoolVar(&flags.BOM, "bom", flags.BOM,
+		"Prepend a UTF-8 byte order mark to the output (shorthand for --output-encoding utf8-bom, which it overrides)")
+	rootCmd.Flags().BoolVar(&flags.FailOnEmpty, "fail-on-empty", flags.FailOnEmpty,
+		"Exit with an error instead of a warning when no files match")
+	rootCmd.Flags().BoolVarP(&flags.Quiet, "quiet", "q", flags.Quiet, "Do not print the success message")
+	rootCmd.Flags().StringVar(&flags.SuccessMessage, "success-message", flags.SuccessMessage,
+		"Go format string printed on success with (output, file count, total bytes, duration); templates with explicit "+
+			"indexes like %[2]d also get output size and output tokens as %[5] and %[6]; an empty string prints no message")
+	rootCmd.Flags().BoolVar(&flags.CIOutput, "ci-output", flags.CIOutput,
+		"After the run, write a JSON summary (files, skipped, total_bytes, output_path, duration_ms, errors) to stderr")
+	rootCmd.Flags().BoolVar(&flags.DryRun, "dry-run", flags.DryRun, "List files that would be included without generating the output file")
+	rootCmd.Flags().BoolVar(&flags.PrintConfig, "print-config", flags.PrintConfig,
+		"Print the effective configuration, annotated with the source of each value, and exit")
+	rootCmd.Flags().BoolVar(&flags.GenerateProfile, "generate-profile", flags.GenerateProfile,
+		"Print the resolved configuration as a .code2md.yaml default profile and exit")
+	rootCmd.Flags().BoolVar(&flags.JSONSchema, "json-schema", flags.JSONSchema,
+		"Print the JSON Schema of the json, jsonl, or llm-chunks output selected with --format (default json) and exit")
 
-	rootCmd.Flags().StringVarP(&cfg.OutputFile, "output", "o", "codebase.md", "Output markdown file")
+	return rootCmd
+}
 
-	if cfg.OutputFile != "" {
-		rootCmd.Flag("output").DefValue = cfg.OutputFile
-	}
+// applyChangedFlags copies the settings of the flags given on the command line from
+// flags to cfg, so that the defaults of the other flags never replace profile or
+// environment values.
+func applyChangedFlags(cmd *cobra.Command, flags, cfg *config.Config) error {
+	var err error
 
-	rootCmd.Flags().StringSliceVarP(&cfg.IncludeExt, "include", "i", []string{}, "File extensions to include (e.g., .go,.py)")
-	rootCmd.Flags().StringSliceVarP(&cfg.ExcludeExt, "exclude", "e", []string{}, "File extensions to exclude")
-	rootCmd.Flags().StringSliceVarP(&cfg.ExcludeDirs, "exclude-dirs", "d", []string{}, "Directories to exclude")
-	rootCmd.Flags().Int64VarP(&cfg.MaxFileSize, "max-size", "s", defaultMaxFileSize, "Maximum file size in bytes (default: 1MB)")
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if !cfg.CopySetting(flags, flagConfigKey(f.Name)) && err == nil {
+			err = fmt.Errorf("%w: --%s", errUnknownFlagSetting, f.Name)
+		}
+	})
 
-	if cfg.MaxFileSize != 0 {
-		rootCmd.Flag("max-size").DefValue = fmt.Sprintf("%d", cfg.MaxFileSize)
-	}
+	return err
+}
 
-	rootCmd.Flags().BoolVarP(&cfg.IncludeHidden, "hidden", "H", false, "Include hidden files and directories")
-	rootCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Verbose output")
-	rootCmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "List files that would be included without generating the output file")
+func runCode2MD(ctx context.Context, cfg *config.Config, logger *zap.Logger, args []string) (err error) {
+	start := time.Now()
 
-	return rootCmd
-}
+	var summary *ciSummary
+
+	if cfg.CIOutput {
+		summary = &ciSummary{Errors: []string{}}
+
+		defer func() {
+			if writeErr := summary.write(os.Stderr, start, err); writeErr != nil {
+				logger.Warn("Failed to write CI summary", zap.Error(writeErr))
+			}
+		}()
+	}
 
-func runCode2MD(ctx context.Context, cfg *config.Config, logger *zap.Logger, args []string) error {
 	targetDir := "."
 	if len(args) > 0 {
 		targetDir = args[0]
 	}
 
+	// A bare --since takes no value, so "--since main" leaves main as the directory.
+	if _, statErr := os.Stat(targetDir); cfg.Since == config.SinceDefaultBranch && len(args) > 0 && os.IsNotExist(statErr) {
+		return fmt.Errorf("%w: %q is not a directory; pass a ref as --since=%s", errSinceRefAsDirectory, targetDir, targetDir)
+	}
+
+	plugins, err := loadPlugins(cfg.Plugins, logger)
+	if err != nil {
+		return err
+	}
+
+	if err := validateConfig(cfg, plugins); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	absPath, err := filepath.Abs(targetDir)
 	if err != nil {
 		return fmt.Errorf("error resolving path: %w", err)
 	}
 
+	if err := checkOutputDir(cfg.OutputDir, absPath); err != nil {
+		return err
+	}
+
+	if cfg.RepoName == "" {
+		cfg.RepoName = gatherer.RepositoryName(ctx, absPath)
+	}
+
+	if cfg.OutputFile == "" {
+		cfg.OutputFile = cfg.RepoName + defaultOutputExtension(cfg.Formats, plugins)
+	}
+
+	// Expand all but {count} now, so that the gatherer excludes the actual output path.
+	template := cfg.OutputFile
+	if cfg.OutputFile, err = expandOutputName(cfg.OutputFile, outputNameVars{
+		repo:      cfg.RepoName,
+		now:       start,
+		keepCount: true,
+	}); err != nil {
+		return err
+	}
+
 	logger.Info("Starting file gathering", zap.String("path", absPath))
 
-	g := gatherer.NewFileGatherer(cfg, absPath, logger)
+	g := gatherer.NewFileGatherer(cfg, absPath, logger, gatherer.WithExcludedOutputs(outputGlobs(template, cfg, plugins)...))
 
-	files, err := g.GatherFiles(ctx)
+	files, err := gatherFiles(ctx, cfg, g)
 	if err != nil {
 		return fmt.Errorf("error gathering files: %w", err)
 	}
 
-	logger.Info("File gathering complete", zap.Int("file_count", len(files)))
+	if cfg.SinceOutput != "" {
+		if files, err = dropPreviousFiles(cfg.SinceOutput, files); err != nil {
+			return err
+		}
+	}
+
+	if cfg.StdinFile != "" && !cfg.DryRun {
+		stdinFile, err := gatherer.ReadStdinFile(os.Stdin, cfg.StdinFile)
+		if err != nil {
+			return err
+		}
+
+		files = append([]gatherer.FileInfo{stdinFile}, files...)
+	}
+
+	summary.recordFiles(files, g.Stats())
+
+	if cfg.Verbose {
+		defer logExtensionStats(logger, g.Stats().Extensions)
+	}
+
+	logger.Info("File gathering complete",
+		zap.Int("file_count", len(files)),
+		zap.Int("skipped_dirs", g.Stats().SkippedDirs),
+	)
+
+	// A dry run reads no content, so there is nothing to grep.
+	if cfg.Grep != "" && !cfg.DryRun {
+		if files, err = generator.GrepFiles(files, cfg.Grep); err != nil {
+			return err
+		}
+	}
+
+	// Writing an output with only a header and an empty TOC would hide the problem.
+	if len(files) == 0 {
+		if cfg.FailOnEmpty {
+			return errNoFiles
+		}
+
+		fmt.Fprintln(os.Stderr, "Warning: No files matched the current configuration; no output was written")
+
+		return nil
+	}
+
+	// {count} is only known now that the files have been gathered.
+	if cfg.OutputFile, err = expandOutputName(cfg.OutputFile, outputNameVars{count: len(files)}); err != nil {
+		return err
+	}
 
 	if cfg.DryRun {
-		fmt.Println("Dry Run: The following files would be included in the output:")
+		return printDryRun(os.Stdout, cfg, files)
+	}
 
-		paths := make([]string, len(files))
-		for i, f := range files {
-			paths[i] = f.Path
+	// Only the markdown document applies the cap; per-file and other formats keep every file.
+	if cfg.OutputDir == "" && (len(cfg.Formats) == 0 || slices.Contains(cfg.Formats, config.FormatMarkdown)) {
+		warnCappedDirs(cfg.MaxFilesPerDir, files, logger)
+	}
+
+	if cfg.OutputDir != "" {
+		if err := generator.NewMarkdownGenerator(cfg).GenerateDirectory(files, cfg.OutputDir); err != nil {
+			return fmt.Errorf("error generating output directory: %w", err)
 		}
 
-		sort.Strings(paths)
+		summary.recordOutputs(cfg.OutputDir)
+		printSuccess(cfg, []string{cfg.OutputDir}, files, start)
+
+		return nil
+	}
 
-		for _, path := range paths {
-			fmt.Println(path)
+	if cfg.SplitSize > 0 || cfg.SplitByDir {
+		outputs, err := generateSplitOutputs(cfg, files, absPath, g.IgnoreSources())
+		if err != nil {
+			return fmt.Errorf("error generating split output: %w", err)
 		}
 
+		summary.recordOutputs(outputs...)
+		warnLargeOutputs(os.Stderr, cfg.WarnOutputSize, outputs)
+		printSuccess(cfg, outputs, files, start)
+
 		return nil
 	}
 
-	gen := generator.NewMarkdownGenerator(cfg)
+	outputs, err := generateOutputs(cfg, files, absPath, g.IgnoreSources(), plugins)
+	if err != nil {
+		return err
+	}
+
+	summary.recordOutputs(outputs...)
+	warnLargeOutputs(os.Stderr, cfg.WarnOutputSize, outputs)
+	printSuccess(cfg, outputs, files, start)
+
+	return nil
+}
+
+// printJSONSchema writes the JSON Schema of the first non-markdown format in Formats,
+// or of the json format when only markdown is selected.
+func printJSONSchema(cmd *cobra.Command, cfg *config.Config) error {
+	format := config.FormatJSON
+	if i := slices.IndexFunc(cfg.Formats, func(f string) bool { return f != config.FormatMarkdown }); i >= 0 {
+		format = cfg.Formats[i]
+	}
 
-	err = gen.GenerateMarkdown(files, absPath)
+	schema, err := generator.JSONSchema(format)
 	if err != nil {
-		return fmt.Errorf("error generating markdown: %w", err)
+		return err
+	}
+
+	_, err = fmt.Fprint(cmd.OutOrStdout(), schema)
+
+	return err
+}
+
+// outputGlobs returns absolute patterns matching the files written for the output file
+// template, with each placeholder matching any text so that earlier dumps match too.
+func outputGlobs(template string, cfg *config.Config, plugins map[string]generator.Generator) []string {
+	var globs []string
+
+	names := formatOutputFiles(outputNameGlob(template), cfg.Formats, plugins)
+	if cfg.SplitSize > 0 {
+		names = append(names, generator.SplitOutputGlobs(outputNameGlob(template))...)
 	}
 
-	fmt.Printf("Successfully generated %s with %d files\n", cfg.OutputFile, len(files))
+	if cfg.SplitByDir {
+		names = append(names, generator.DirOutputDir(outputNameGlob(template)))
+	}
+
+	for _, name := range names {
+		if glob, err := filepath.Abs(name); err == nil {
+			globs = append(globs, glob)
+		}
+	}
+
+	return globs
+}
+
+// generateSplitOutputs writes the markdown split by size or by top-level directory.
+func generateSplitOutputs(
+	cfg *config.Config, files []gatherer.FileInfo, absPath string, ignoreSources []gatherer.IgnoreSource,
+) ([]string, error) {
+	gen := generator.NewMarkdownGenerator(cfg).WithIgnoreSources(ignoreSources)
+	if cfg.SplitByDir {
+		return gen.GenerateByDir(files, absPath)
+	}
+
+	return gen.GenerateParts(files, absPath)
+}
+
+// warnCappedDirs warns about each directory with more gathered files than the
+// --max-files-per-dir cap, since the extra files are left out of the markdown.
+func warnCappedDirs(limit int, files []gatherer.FileInfo, logger *zap.Logger) {
+	if limit <= 0 {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, file := range files {
+		counts[path.Dir(file.Path)]++
+	}
+
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+		if counts[dir] > limit {
+			logger.Warn("Directory exceeds --max-files-per-dir; extra files are omitted",
+				zap.String("dir", dir), zap.Int("files", counts[dir]), zap.Int("max_files_per_dir", limit))
+
+			delete(counts, dir) // Warn once per directory.
+		}
+	}
+}
+
+// logExtensionStats logs, for each extension encountered, how many files were included
+// and how many were skipped as too large, binary, or gitignored, sorted by extension.
+func logExtensionStats(logger *zap.Logger, stats map[string]gatherer.ExtensionStats) {
+	for _, ext := range slices.Sorted(maps.Keys(stats)) {
+		logger.Info("Extension statistics",
+			zap.String("extension", ext),
+			zap.Int("included", stats[ext].Included),
+			zap.Int("too_large", stats[ext].TooLarge),
+			zap.Int("binary", stats[ext].Binary),
+			zap.Int("gitignored", stats[ext].Gitignored),
+		)
+	}
+}
+
+// gatherFiles gathers the files with their content, or only their metadata in dry-run mode.
+func gatherFiles(ctx context.Context, cfg *config.Config, g *gatherer.FileGatherer) ([]gatherer.FileInfo, error) {
+	if !cfg.DryRun {
+		return g.GatherFiles(ctx)
+	}
+
+	stats, err := g.DryRun(ctx)
+
+	return stats.Files, err
+}
+
+// explicitArgIndex matches a formatting verb with an explicit argument index, like %[5]s.
+var explicitArgIndex = regexp.MustCompile(`%[-+# 0-9.*]*\[\d+\]`)
+
+// printSuccess prints the success message template with the output path, file count,
+// total input bytes, and elapsed time as arguments. Templates with explicit argument
+// indexes also get the output size and estimated output tokens; other templates keep
+// the four arguments they were written for. Nothing is printed in quiet mode or for an
+// empty template.
+func printSuccess(cfg *config.Config, outputs []string, files []gatherer.FileInfo, start time.Time) {
+	if cfg.Quiet || cfg.SuccessMessage == "" {
+		return
+	}
+
+	var totalBytes int64
+	for _, file := range files {
+		totalBytes += file.Size
+	}
+
+	args := []any{strings.Join(outputs, ", "), len(files), totalBytes, time.Since(start).Round(time.Millisecond)}
+
+	if explicitArgIndex.MatchString(cfg.SuccessMessage) {
+		outputBytes := outputSize(outputs)
+		args = append(args, generator.FormatBytes(outputBytes), formatTokens(generator.EstimateTokens(outputBytes)))
+	}
+
+	fmt.Printf(cfg.SuccessMessage+"\n", args...)
+}
+
+// outputSize returns the total size of the written outputs, walking output directories.
+// Paths that cannot be read are not counted.
+func outputSize(outputs []string) int64 {
+	var size int64
+
+	for _, output := range outputs {
+		_ = filepath.WalkDir(output, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil //nolint:nilerr // Unreadable entries only make the reported size an estimate.
+			}
+
+			if info, infoErr := d.Info(); infoErr == nil {
+				size += info.Size()
+			}
+
+			return nil
+		})
+	}
+
+	return size
+}
+
+// warnLargeOutputs warns about each output larger than limit bytes, which some tools and
+// LLMs struggle with. The outputs are kept either way. A limit of 0 disables the check.
+func warnLargeOutputs(w io.Writer, limit int64, outputs []string) {
+	if limit <= 0 {
+		return
+	}
+
+	for _, output := range outputs {
+		if size := outputSize([]string{output}); size > limit {
+			fmt.Fprintf(w, "Warning: Output file %s is %s, which may be too large for some LLMs. "+
+				"Consider using --max-file-tokens, --exclude-patterns, or --split-size to reduce it.\n",
+				output, generator.FormatBytes(size))
+		}
+	}
+}
+
+// formatTokens renders a token count compactly, e.g. 950, 540k, or 1.2M.
+func formatTokens(tokens int64) string {
+	switch {
+	case tokens < 1000:
+		return strconv.FormatInt(tokens, 10)
+	case tokens < 1000*1000:
+		return fmt.Sprintf("%dk", tokens/1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(tokens)/(1000*1000))
+	}
+}
+
+// validateConfig checks the resolved configuration for contradictory or out-of-range
+// settings and returns an error describing the first violation found.
+func validateConfig(cfg *config.Config, plugins map[string]generator.Generator) error {
+	for _, ext := range cfg.IncludeExt {
+		if slices.Contains(cfg.ExcludeExt, ext) {
+			return fmt.Errorf("%w: %q", errConflictingExtensions, ext)
+		}
+	}
+
+	if cfg.Since != "" && cfg.SinceTag != "" {
+		return errConflictingSince
+	}
+
+	if cfg.ExcludeTests && cfg.TestsOnly {
+		return errConflictingTests
+	}
+
+	if cfg.SplitSize > 0 && cfg.SplitByDir {
+		return errConflictingSplit
+	}
+
+	if (cfg.SplitSize > 0 || cfg.SplitByDir) &&
+		slices.ContainsFunc(cfg.Formats, func(format string) bool { return format != config.FormatMarkdown }) {
+		return errSplitFormat
+	}
+
+	if len(cfg.Summarize) > 0 && cfg.Template != "" {
+		return errSummarizeTemplate
+	}
+
+	if len(cfg.Summarize) > 0 &&
+		slices.ContainsFunc(cfg.Formats, func(format string) bool { return format != config.FormatMarkdown }) {
+		return errSummarizeFormat
+	}
+
+	switch cfg.Sort {
+	case "", config.SortPath, config.SortLanguage:
+	default:
+		return fmt.Errorf("%w: %q (supported: %s, %s)", errUnknownSort, cfg.Sort, config.SortPath, config.SortLanguage)
+	}
+
+	if cfg.MaxFileSize <= 0 {
+		return fmt.Errorf("%w: %d", errInvalidMaxFileSize, cfg.MaxFileSize)
+	}
+
+	if cfg.MaxFileTokens < 0 {
+		return fmt.Errorf("%w: %d", errNegativeMaxFileTokens, cfg.MaxFileTokens)
+	}
+
+	if cfg.MaxFiles < 0 {
+		return fmt.Errorf("%w: %d", errNegativeMaxFiles, cfg.MaxFiles)
+	}
+
+	if cfg.MaxReadBytesPerSec < 0 {
+		return fmt.Errorf("%w: %d", errNegativeReadThroughput, cfg.MaxReadBytesPerSec)
+	}
+
+	if cfg.MaxMemory < 0 {
+		return fmt.Errorf("%w: %d", errNegativeMaxMemory, cfg.MaxMemory)
+	}
+
+	if cfg.WrapWidth < 0 {
+		return fmt.Errorf("%w: %d", errNegativeWrapWidth, cfg.WrapWidth)
+	}
+
+	if _, err := generator.WritesBOM(cfg); err != nil {
+		return err
+	}
+
+	for _, ignoreFile := range cfg.IgnoreFiles {
+		if _, err := os.Stat(ignoreFile); err != nil {
+			return fmt.Errorf("%w: %q", errIgnoreFileNotFound, ignoreFile)
+		}
+	}
+
+	for _, format := range cfg.Formats {
+		if _, ok := formatExtension(format, plugins); !ok {
+			return fmt.Errorf("%w: %q", errUnknownFormat, format)
+		}
+	}
+
+	// Placeholders are expanded after gathering, but a typo should fail before the walk.
+	if _, err := expandOutputName(cfg.OutputFile, outputNameVars{}); err != nil {
+		return err
+	}
 
 	return nil
 }
+
+// checkOutputDir rejects an output directory that contains, or is contained in, the input directory,
+// since the generated files would otherwise be gathered on the next run or overwrite sources.
+func checkOutputDir(outputDir, absPath string) error {
+	if outputDir == "" {
+		return nil
+	}
+
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("error resolving output directory: %w", err)
+	}
+
+	if isWithin(absOutput, absPath) || isWithin(absPath, absOutput) {
+		return fmt.Errorf("%w: %q and %q", errOutputDirOverlap, absOutput, absPath)
+	}
+
+	return nil
+}
+
+// isWithin reports whether path is dir or lies beneath it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// formatExtensions maps each output format to the file extension used when
+// several formats are written in one run.
+func formatExtensions() map[string]string {
+	return map[string]string{
+		config.FormatMarkdown:  ".md",
+		config.FormatJSON:      ".json",
+		config.FormatJSONL:     ".jsonl",
+		config.FormatLLMChunks: ".chunks.json",
+	}
+}
+
+// defaultOutputExtension returns the extension of the default output file: that of the
+// format when a single format is selected, and .md otherwise.
+func defaultOutputExtension(formats []string, plugins map[string]generator.Generator) string {
+	if len(formats) == 1 {
+		if ext, ok := formatExtension(formats[0], plugins); ok {
+			return ext
+		}
+	}
+
+	return formatExtensions()[config.FormatMarkdown]
+}
+
+// formatOutputFiles returns the output file of each format. With a single format, name is
+// used as is; with several, it is a base name and each output gets its format's extension.
+func formatOutputFiles(name string, formats []string, plugins map[string]generator.Generator) []string {
+	if len(formats) <= 1 {
+		return []string{name}
+	}
+
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	files := make([]string, len(formats))
+
+	for i, format := range formats {
+		ext, _ := formatExtension(format, plugins)
+		files[i] = base + ext
+	}
+
+	return files
+}
+
+// newGenerator returns the generator that renders the given output format.
+func newGenerator(format string, cfg *config.Config, ignoreSources []gatherer.IgnoreSource) generator.Generator {
+	switch format {
+	case config.FormatJSON:
+		return generator.NewJSONGenerator(cfg)
+	case config.FormatJSONL:
+		return generator.NewJSONLinesGenerator(cfg)
+	case config.FormatLLMChunks:
+		return generator.NewChunkGenerator(cfg)
+	default:
+		return generator.NewMarkdownGenerator(cfg).WithIgnoreSources(ignoreSources)
+	}
+}
+
+// generateOutputs writes one output per configured format, reusing the same gathered files,
+// and returns the paths written. With several formats, OutputFile is treated as a base name
+// and each output gets its format's extension.
+func generateOutputs(
+	cfg *config.Config, files []gatherer.FileInfo, absPath string, ignoreSources []gatherer.IgnoreSource,
+	plugins map[string]generator.Generator,
+) ([]string, error) {
+	formats := cfg.Formats
+	if len(formats) == 0 {
+		formats = []string{config.FormatMarkdown}
+	}
+
+	outputFiles := formatOutputFiles(cfg.OutputFile, formats, plugins)
+	outputs := make([]string, 0, len(formats))
+
+	for i, format := range formats {
+		if _, ok := formatExtension(format, plugins); !ok {
+			return nil, fmt.Errorf("%w: %q", errUnknownFormat, format)
+		}
+
+		formatCfg := *cfg
+		formatCfg.OutputFile = outputFiles[i]
+
+		gen, ok := plugins[format]
+		if !ok {
+			gen = newGenerator(format, &formatCfg, ignoreSources)
+		}
+
+		gen, err := withNoContent(gen, &formatCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		if genErr := generator.WriteFile(gen, formatCfg.OutputFile, files, absPath); genErr != nil {
+			return nil, fmt.Errorf("error generating %s: %w", format, genErr)
+		}
+
+		outputs = append(outputs, formatCfg.OutputFile)
+	}
+
+	return outputs, nil
+}
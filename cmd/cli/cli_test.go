@@ -3,14 +3,27 @@ package cli
 import (
 	"bytes"
 	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"code2md/internal/generator"
+	"code2md/pkg/plugin"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	goplugin "plugin"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // setupTestFileSystem is a helper to create a temporary directory with files for testing.
@@ -89,3 +102,1043 @@ func TestRunCode2MD_DryRun(t *testing.T) {
 		t.Errorf("Expected output file %q NOT to be created in dry run mode, but it was.", finalOutputPath)
 	}
 }
+
+func TestRunCode2MD_OutputIsDirectory(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+	outputDir := t.TempDir()
+
+	cfg := &config.Config{
+		OutputFile:  outputDir,
+		MaxFileSize: 1024 * 1024,
+	}
+
+	err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir})
+	if !errors.Is(err, generator.ErrOutputIsDirectory) {
+		t.Fatalf("Expected ErrOutputIsDirectory, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "specify a file name") {
+		t.Errorf("Expected error to suggest a file name, got %q", err.Error())
+	}
+}
+
+func TestRunCode2MD_MultipleFormats(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+	outputBase := filepath.Join(t.TempDir(), "codebase")
+
+	cfg := &config.Config{
+		OutputFile:  outputBase,
+		MaxFileSize: 1024 * 1024,
+		Formats:     []string{config.FormatMarkdown, config.FormatJSON},
+	}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(outputBase + ".md"); err != nil {
+		t.Errorf("Expected markdown output to exist: %v", err)
+	}
+
+	data, err := os.ReadFile(outputBase + ".json")
+	if err != nil {
+		t.Fatalf("Expected JSON output to exist: %v", err)
+	}
+
+	var doc generator.JSONDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+
+	if doc.FileCount != len(doc.Files) || doc.FileCount == 0 {
+		t.Errorf("Expected a non-empty file list matching file_count, got count=%d files=%d", doc.FileCount, len(doc.Files))
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      config.Config
+		expected error
+	}{
+		{"Valid", config.Config{MaxFileSize: 1024, IncludeExt: []string{".go"}, ExcludeExt: []string{".md"}}, nil},
+		{"Conflicting extensions", config.Config{MaxFileSize: 1024, IncludeExt: []string{".go"}, ExcludeExt: []string{".go"}},
+			errConflictingExtensions},
+		{"Zero max size", config.Config{}, errInvalidMaxFileSize},
+		{"Negative wrap width", config.Config{MaxFileSize: 1024, WrapWidth: -1}, errNegativeWrapWidth},
+		{"Unknown format", config.Config{MaxFileSize: 1024, Formats: []string{"pdf"}}, errUnknownFormat},
+		{"Negative max files", config.Config{MaxFileSize: 1024, MaxFiles: -1}, errNegativeMaxFiles},
+		{"Conflicting test filters", config.Config{MaxFileSize: 1024, ExcludeTests: true, TestsOnly: true}, errConflictingTests},
+		{"Unknown encoding", config.Config{MaxFileSize: 1024, OutputEncoding: "latin1", BOM: true}, generator.ErrUnknownEncoding},
+		{"Unknown sort", config.Config{MaxFileSize: 1024, Sort: "size"}, errUnknownSort},
+		{"Summarize with template", config.Config{MaxFileSize: 1024, Summarize: []string{"*.go"}, Template: "t.tmpl"}, errSummarizeTemplate},
+		{"Summarize with JSON", config.Config{MaxFileSize: 1024, Summarize: []string{"*.go"}, Formats: []string{"jsonl"}}, errSummarizeFormat},
+		{
+			"Summarize with chunks",
+			config.Config{MaxFileSize: 1024, Summarize: []string{"*.go"}, Formats: []string{"llm-chunks"}},
+			errSummarizeFormat,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateConfig(&tc.cfg, nil)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("validateConfig(): expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestPrintConfig_FlagOverridesEnv(t *testing.T) {
+	t.Setenv("CODE2MD_WRAP_WIDTH", "10")
+	t.Setenv("CODE2MD_SEED_PROMPT", "review")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() returned an unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	cmd := createRootCommand(cfg, zap.NewNop())
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{"--print-config", "--wrap-width", "80", t.TempDir()})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() returned an unexpected error: %v", err)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{"wrap_width: 80 # flag", "seed_prompt: review # env", "max_size: 1048576 # default"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected printed config to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestCreateRootCommand_FlagsKeepProfileValues(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	profile := "profiles:\n  default:\n    wrap_width: 33\n    include_hidden: true\n    format: [json]\n"
+	if err := os.WriteFile(config.FileName, []byte(profile), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", config.FileName, err)
+	}
+
+	for _, tc := range []struct {
+		args      []string
+		wrapWidth int
+	}{
+		{nil, 33},
+		{[]string{"--wrap-width", "80"}, 80},
+	} {
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("config.Load() returned an unexpected error: %v", err)
+		}
+
+		cmd := createRootCommand(cfg, zap.NewNop())
+		cmd.SetOut(io.Discard)
+		cmd.SetArgs(append([]string{"--print-config"}, tc.args...))
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("Execute(%v) returned an unexpected error: %v", tc.args, err)
+		}
+
+		if cfg.WrapWidth != tc.wrapWidth || !cfg.IncludeHidden || !slices.Equal(cfg.Formats, []string{config.FormatJSON}) {
+			t.Errorf("Execute(%v): expected wrap width %d and the profile's other values, got %d, %v, %v",
+				tc.args, tc.wrapWidth, cfg.WrapWidth, cfg.IncludeHidden, cfg.Formats)
+		}
+	}
+
+	// Every flag must name a setting, or applyChangedFlags cannot copy it.
+	createRootCommand(config.NewConfig(), zap.NewNop()).Flags().VisitAll(func(f *pflag.Flag) {
+		if !config.NewConfig().CopySetting(config.NewConfig(), flagConfigKey(f.Name)) {
+			t.Errorf("Flag --%s has no configuration setting", f.Name)
+		}
+	})
+}
+
+func TestRunCode2MD_OutputNamedAfterRepository(t *testing.T) {
+	projectDir := filepath.Join(t.TempDir(), "coolproject")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0600); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	t.Chdir(t.TempDir())
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projectDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	if cfg.OutputFile != "coolproject.md" {
+		t.Errorf("Expected default output file %q, got %q", "coolproject.md", cfg.OutputFile)
+	}
+
+	data, err := os.ReadFile("coolproject.md")
+	if err != nil {
+		t.Fatalf("Expected coolproject.md to be written: %v", err)
+	}
+
+	if !strings.Contains(string(data), "**Repository:** coolproject (`"+projectDir+"`)") {
+		t.Errorf("Expected the header to show the repository name, got:\n%s", data)
+	}
+}
+
+func TestRunCode2MD_DefaultOutputUsesFormatExtension(t *testing.T) {
+	projectDir := filepath.Join(t.TempDir(), "coolproject")
+	if err := os.MkdirAll(projectDir, 0o750); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	// The second run must not gather the JSON written by the first.
+	for run := 1; run <= 2; run++ {
+		t.Chdir(projectDir)
+
+		cfg := &config.Config{MaxFileSize: 1024 * 1024, Formats: []string{config.FormatJSON}}
+		if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projectDir}); err != nil {
+			t.Fatalf("Run %d: runCode2MD returned an unexpected error: %v", run, err)
+		}
+
+		if cfg.OutputFile != "coolproject.json" {
+			t.Errorf("Run %d: expected default output file %q, got %q", run, "coolproject.json", cfg.OutputFile)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "coolproject.json"))
+	if err != nil {
+		t.Fatalf("Expected coolproject.json to be written: %v", err)
+	}
+
+	if !strings.Contains(string(data), `"main.go"`) || strings.Contains(string(data), `"coolproject.json"`) {
+		t.Errorf("Expected the output to hold main.go only, got:\n%s", data)
+	}
+
+	if _, err := os.Stat(filepath.Join(projectDir, "coolproject.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected no markdown output, got err=%v", err)
+	}
+}
+
+func TestRunCode2MD_OutputDir(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	cfg := &config.Config{OutputDir: outputDir, MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "internal", "helper.go.md"))
+	if err != nil {
+		t.Fatalf("Expected a per-file output for internal/helper.go: %v", err)
+	}
+
+	if !strings.Contains(string(data), "```go\npackage internal\n```") {
+		t.Errorf("Expected the per-file output to contain the fenced content, got:\n%s", data)
+	}
+
+	cfg.OutputDir = filepath.Join(tmpDir, "docs")
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); !errors.Is(err, errOutputDirOverlap) {
+		t.Errorf("Expected errOutputDirOverlap for an output directory inside the input, got %v", err)
+	}
+}
+
+func TestRunCode2MD_OutputDirRejectsEscapingPaths(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+	outputDir := filepath.Join(t.TempDir(), "out", "docs")
+
+	// Relative to internal, the root files become ../main.go and would land in out.
+	cfg := &config.Config{OutputDir: outputDir, RelativeTo: filepath.Join(tmpDir, "internal"), MaxFileSize: 1024 * 1024}
+
+	err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir})
+	if !errors.Is(err, generator.ErrPathOutsideOutputDir) {
+		t.Fatalf("Expected ErrPathOutsideOutputDir, got %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(outputDir))
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing written next to the output directory, got %v", entries)
+	}
+}
+
+func TestRunCode2MD_GrepAppliesToEveryOutput(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+
+	outputFile := filepath.Join(t.TempDir(), "out.json")
+	cfg := &config.Config{OutputFile: outputFile, Formats: []string{config.FormatJSON}, Grep: "^package main$", MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("runCode2MD() returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read the JSON output: %v", err)
+	}
+
+	if !strings.Contains(string(data), `"content": "1: package main\n"`) || strings.Contains(string(data), "helper.go") {
+		t.Errorf("Expected the JSON output to hold only the grep matches of main.go, got:\n%s", data)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "docs")
+	cfg = &config.Config{OutputDir: outputDir, Grep: "^package main$", MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("runCode2MD() returned an unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "main.go.md")); err != nil {
+		t.Errorf("Expected main.go.md in the output directory: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "internal", "helper.go.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected helper.go, which does not match, to be left out of the output directory, got %v", err)
+	}
+}
+
+func TestRunCode2MD_CIOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{"main.go": "package main", "README.md": "# Test", "logo.png": "\x00PNG"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+	cfg := &config.Config{OutputFile: outputFile, MaxFileSize: 1024 * 1024, CIOutput: true}
+
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	runErr := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir})
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close pipe writer: %v", err)
+	}
+
+	os.Stderr = oldStderr
+
+	if runErr != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", runErr)
+	}
+
+	var summary ciSummary
+	if err := json.NewDecoder(r).Decode(&summary); err != nil {
+		t.Fatalf("Failed to decode CI summary from stderr: %v", err)
+	}
+
+	expected := ciSummary{
+		Files:      2,
+		Skipped:    1,
+		TotalBytes: int64(len("package main") + len("# Test")),
+		OutputPath: outputFile,
+		DurationMS: summary.DurationMS,
+		Errors:     []string{},
+	}
+
+	if summary.Files != expected.Files || summary.Skipped != expected.Skipped ||
+		summary.TotalBytes != expected.TotalBytes || summary.OutputPath != expected.OutputPath || len(summary.Errors) != 0 {
+		t.Errorf("Expected CI summary %+v, got %+v", expected, summary)
+	}
+}
+
+func TestGenerateProfile_RoundTrips(t *testing.T) {
+	t.Setenv("CODE2MD_SEED_PROMPT", "review")
+	t.Chdir(t.TempDir())
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() returned an unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	cmd := createRootCommand(cfg, zap.NewNop())
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{"--generate-profile", "--wrap-width", "80", "--exclude-dirs", "dist"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() returned an unexpected error: %v", err)
+	}
+
+	output := buf.String()
+
+	for _, expected := range []string{"profiles:\n  default:\n", "wrap_width: 80 # flag", "seed_prompt: review # env"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected generated profile to contain %q, got:\n%s", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "generate_profile") {
+		t.Errorf("Expected generate_profile to be left out of the profile, got:\n%s", output)
+	}
+
+	// Loading the generated file without the env or flags reproduces the settings.
+	if err := os.Unsetenv("CODE2MD_SEED_PROMPT"); err != nil {
+		t.Fatalf("Failed to unset CODE2MD_SEED_PROMPT: %v", err)
+	}
+
+	if err := os.WriteFile(config.FileName, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", config.FileName, err)
+	}
+
+	loaded, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() of the generated profile returned an unexpected error: %v", err)
+	}
+
+	if loaded.WrapWidth != 80 || loaded.SeedPrompt != "review" || !slices.Equal(loaded.ExcludeDirs, []string{"dist"}) {
+		t.Errorf("Expected the generated profile to reproduce the settings, got %+v", loaded)
+	}
+}
+
+func TestRunCode2MD_ShowIgnoreRules(t *testing.T) {
+	tmpDir := setupTestFileSystem(t)
+	if err := os.WriteFile(filepath.Join(tmpDir, ".gitignore"), []byte("# logs\n*.log\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+	cfg := &config.Config{OutputFile: outputFile, MaxFileSize: 1024 * 1024, ShowIgnoreRules: true}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	if !strings.Contains(string(data), "## Appendix: Ignore Rules\n\n### `.gitignore`\n\n- `*.log`\n") {
+		t.Errorf("Expected the appendix to list *.log under .gitignore, got:\n%s", data)
+	}
+}
+
+func TestRunCode2MD_NoMatchingFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "image.go"), []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, 0o600); err != nil {
+		t.Fatalf("Failed to write binary file: %v", err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+	cfg := &config.Config{OutputFile: outputFile, MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); err != nil {
+		t.Fatalf("Expected only a warning without --fail-on-empty, got %v", err)
+	}
+
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file to be written, got %v", err)
+	}
+
+	cfg.FailOnEmpty = true
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{tmpDir}); !errors.Is(err, errNoFiles) {
+		t.Errorf("Expected errNoFiles with --fail-on-empty, got %v", err)
+	}
+}
+
+func TestPrintSuccess(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12}, {Path: "README.md", Size: 6}}
+
+	output := filepath.Join(t.TempDir(), "out.md")
+	if err := os.WriteFile(output, bytes.Repeat([]byte("x"), 2560), 0o600); err != nil {
+		t.Fatalf("Failed to write output file: %v", err)
+	}
+
+	capture := func(cfg *config.Config) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		printSuccess(cfg, []string{output}, files, time.Now())
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close pipe writer: %v", err)
+		}
+
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			t.Fatalf("Failed to read from pipe reader: %v", err)
+		}
+
+		return buf.String()
+	}
+
+	expected := "Successfully generated " + output + " (2 files, 2.5 KB, ~640 tokens)\n"
+	if got := capture(&config.Config{SuccessMessage: config.DefaultSuccessMessage}); got != expected {
+		t.Errorf("Unexpected default message %q", got)
+	}
+
+	custom := &config.Config{SuccessMessage: "::notice::%[2]d files (%[3]d bytes)"}
+	if got := capture(custom); got != "::notice::2 files (18 bytes)\n" {
+		t.Errorf("Unexpected custom message %q", got)
+	}
+
+	if got := capture(&config.Config{SuccessMessage: "%[5]s, ~%[6]s tokens"}); got != "2.5 KB, ~640 tokens\n" {
+		t.Errorf("Expected a positional template to get the output size and tokens, got %q", got)
+	}
+
+	sequential := capture(&config.Config{SuccessMessage: "Wrote %s with %d files (%d bytes) in %v"})
+	if !strings.HasPrefix(sequential, "Wrote "+output+" with 2 files (18 bytes) in ") || strings.Contains(sequential, "EXTRA") {
+		t.Errorf("Expected a four-verb message without extra arguments, got %q", sequential)
+	}
+
+	if got := capture(&config.Config{}); got != "" {
+		t.Errorf("Expected an empty template to print nothing, got %q", got)
+	}
+
+	if got := capture(&config.Config{SuccessMessage: config.DefaultSuccessMessage, Quiet: true}); got != "" {
+		t.Errorf("Expected quiet mode to print nothing, got %q", got)
+	}
+
+	if got := formatTokens(540_400); got != "540k" {
+		t.Errorf("Expected formatTokens(540400) to be 540k, got %q", got)
+	}
+}
+
+func TestIgnoreCheckCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	writeFile(".gitignore", "*.log\nbuild/\n")
+	writeFile("main.go", "package main\n")
+	writeFile("debug.log", "oops\n")
+	writeFile("build/app", "binary\n")
+
+	cmd := newIgnoreCheckCommand(&config.Config{}, config.NewConfig(), zap.NewNop())
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{tmpDir})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ignore-check returned an unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"| build/ | .gitignore | `build/` |\n",
+		"| debug.log | .gitignore | `*.log` |\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), "main.go") {
+		t.Errorf("Expected main.go not to be listed, got:\n%s", out.String())
+	}
+}
+
+func TestRunCode2MD_OutputPlaceholders(t *testing.T) {
+	projDir := filepath.Join(t.TempDir(), "proj")
+	if err := os.MkdirAll(projDir, 0o750); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projDir, "main.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	outDir := t.TempDir()
+	cfg := &config.Config{OutputFile: filepath.Join(outDir, "dump-{repo}-{count}.md"), MaxFileSize: 1024 * 1024}
+
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "dump-proj-1.md")); err != nil {
+		t.Errorf("Expected dump-proj-1.md to be created: %v", err)
+	}
+
+	cfg = &config.Config{OutputFile: filepath.Join(outDir, "dump-{branch}.md"), MaxFileSize: 1024 * 1024}
+
+	err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir})
+	if !errors.Is(err, errUnknownPlaceholder) {
+		t.Errorf("Expected errUnknownPlaceholder, got %v", err)
+	}
+}
+
+func TestRunCode2MD_OutputPlaceholdersExcludeDumps(t *testing.T) {
+	projDir := filepath.Join(t.TempDir(), "proj")
+	if err := os.MkdirAll(projDir, 0o750); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"main.go":                 "package main\n",
+		"dump-proj-2024-01-01.md": "# An earlier dump\n",
+	} {
+		if err := os.WriteFile(filepath.Join(projDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	for run := 1; run <= 2; run++ {
+		cfg := &config.Config{OutputFile: filepath.Join(projDir, "dump-{repo}.md"), MaxFileSize: 1024 * 1024}
+		if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+			t.Fatalf("Run %d: runCode2MD returned an unexpected error: %v", run, err)
+		}
+	}
+
+	cfg := &config.Config{OutputFile: filepath.Join(projDir, "dump-{repo}-{date}.md"), MaxFileSize: 1024 * 1024}
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	// Each output leaves out itself and the earlier dumps that match its template.
+	for name, dump := range map[string]string{
+		"dump-proj.md": "### dump-",
+		"dump-proj-" + time.Now().Format("2006-01-02") + ".md": "### dump-proj-",
+	} {
+		data, err := os.ReadFile(filepath.Join(projDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be created: %v", name, err)
+		}
+
+		if strings.Contains(string(data), dump) {
+			t.Errorf("Expected %s to leave out the dumps, got:\n%s", name, data)
+		}
+	}
+}
+
+func TestRunCode2MD_SplitOutputsExcluded(t *testing.T) {
+	projDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projDir, "main.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		cfg := &config.Config{OutputFile: filepath.Join(projDir, "dump.md"), MaxFileSize: 1024 * 1024, SplitSize: 1024}
+		if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+			t.Fatalf("Run %d: runCode2MD returned an unexpected error: %v", run, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(projDir, "dump-part1.md"))
+	if err != nil {
+		t.Fatalf("Expected dump-part1.md to be created: %v", err)
+	}
+
+	if strings.Contains(string(data), "### dump-") {
+		t.Errorf("Expected the rerun to leave out the parts and the index, got:\n%s", data)
+	}
+
+	if _, err := os.Stat(filepath.Join(projDir, "dump-index.md")); err != nil {
+		t.Errorf("Expected dump-index.md to be created: %v", err)
+	}
+}
+
+func TestRunCode2MD_DirOutputsExcluded(t *testing.T) {
+	projDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projDir, "cmd"), 0o750); err != nil {
+		t.Fatalf("Failed to create cmd: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projDir, "cmd", "main.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		cfg := &config.Config{OutputFile: filepath.Join(projDir, "dump.md"), MaxFileSize: 1024 * 1024, SplitByDir: true}
+		if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+			t.Fatalf("Run %d: runCode2MD returned an unexpected error: %v", run, err)
+		}
+	}
+
+	// The rerun would write the earlier cmd.md into a dump-dirs.md of its own.
+	if _, err := os.Stat(filepath.Join(projDir, "dump-dirs", "dump-dirs.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected the rerun to leave out dump-dirs, got err=%v", err)
+	}
+}
+
+func TestLoadPlugins_MissingFile(t *testing.T) {
+	if _, err := loadPlugins([]string{filepath.Join(t.TempDir(), "missing.so")}, zap.NewNop()); err == nil {
+		t.Error("Expected an error for a missing plugin file")
+	}
+
+	plugins := map[string]generator.Generator{"filelist": generator.NewJSONLinesGenerator(&config.Config{})}
+	if ext, ok := formatExtension("filelist", plugins); !ok || ext != ".filelist" {
+		t.Errorf("Expected plugin format extension .filelist, got %q (known: %v)", ext, ok)
+	}
+
+	if err := validateConfig(&config.Config{MaxFileSize: 1024, Formats: []string{"filelist"}}, plugins); err != nil {
+		t.Errorf("Expected a plugin format to be accepted, got %v", err)
+	}
+}
+
+// pathListPlugin is a plugin generator that writes the path and language of each file.
+type pathListPlugin struct{}
+
+func (pathListPlugin) Generate(w io.Writer, files []plugin.File, _ string) error {
+	for _, file := range files {
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", file.Path, file.Language, file.Content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestPluginSymbols(t *testing.T) {
+	var (
+		gen    plugin.Generator = pathListPlugin{}
+		nilGen plugin.Generator
+		name   = func() string { return "paths" }
+	)
+
+	testCases := []struct {
+		name    string
+		symbols map[string]goplugin.Symbol
+		valid   bool
+	}{
+		{"valid", map[string]goplugin.Symbol{plugin.GeneratorSymbol: &gen, plugin.NameSymbol: name}, true},
+		{"missing generator", map[string]goplugin.Symbol{plugin.NameSymbol: name}, false},
+		{"generator not a variable", map[string]goplugin.Symbol{plugin.GeneratorSymbol: gen, plugin.NameSymbol: name}, false},
+		{"nil generator", map[string]goplugin.Symbol{plugin.GeneratorSymbol: &nilGen, plugin.NameSymbol: name}, false},
+		{"missing name", map[string]goplugin.Symbol{plugin.GeneratorSymbol: &gen}, false},
+		{"empty name", map[string]goplugin.Symbol{plugin.GeneratorSymbol: &gen, plugin.NameSymbol: func() string { return "" }}, false},
+		{"name of wrong type", map[string]goplugin.Symbol{plugin.GeneratorSymbol: &gen, plugin.NameSymbol: "paths"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookup := func(symbol string) (goplugin.Symbol, error) {
+				if s, ok := tc.symbols[symbol]; ok {
+					return s, nil
+				}
+
+				return nil, fmt.Errorf("symbol %s not found", symbol)
+			}
+
+			format, loaded, err := pluginSymbols("paths.so", lookup)
+			if !tc.valid {
+				if !errors.Is(err, errInvalidPlugin) {
+					t.Errorf("Expected errInvalidPlugin, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil || format != "paths" {
+				t.Fatalf("pluginSymbols() = %q, %v; expected format paths", format, err)
+			}
+
+			var buf bytes.Buffer
+
+			files := []gatherer.FileInfo{{Path: "a.go", Language: "go", RawContent: []byte("package a")}}
+			if err := loaded.Generate(&buf, files, "/repo"); err != nil {
+				t.Fatalf("Generate() returned an unexpected error: %v", err)
+			}
+
+			if buf.String() != "a.go go package a\n" {
+				t.Errorf("Expected the plugin to receive the file, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestWithNoContent_Plugin(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.NoContentFor = []string{"secret.txt"}
+
+	gen, err := withNoContent(pluginGenerator{Generator: pathListPlugin{}}, cfg)
+	if err != nil {
+		t.Fatalf("withNoContent() returned an unexpected error: %v", err)
+	}
+
+	files := []gatherer.FileInfo{
+		{Path: "a.go", Language: "go", Content: "package a"},
+		{Path: "secret.txt", Language: "text", Content: "hunter2"},
+	}
+
+	var buf bytes.Buffer
+	if err := gen.Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	if buf.String() != "a.go go package a\nsecret.txt text \n" {
+		t.Errorf("Expected the plugin to receive secret.txt without its content, got %q", buf.String())
+	}
+}
+
+func TestPrintDryRun_JSON(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 12, Language: "go"},
+		{Path: "README.md", Size: 30, Language: "markdown"},
+	}
+
+	var buf bytes.Buffer
+	if err := printDryRun(&buf, &config.Config{Formats: []string{config.FormatJSON}}, files); err != nil {
+		t.Fatalf("printDryRun returned an unexpected error: %v", err)
+	}
+
+	var report dryRunReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Expected valid JSON, got %v:\n%s", err, buf.String())
+	}
+
+	if report.Count != 2 || len(report.Files) != 2 || report.TotalSize != 42 {
+		t.Errorf("Expected 2 files totaling 42 bytes, got %+v", report)
+	}
+
+	if report.Files[0].Path != "README.md" || report.Files[1].Language != "go" {
+		t.Errorf("Expected files sorted by path with languages, got %+v", report.Files)
+	}
+}
+
+func TestWarnCappedDirs(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	files := []gatherer.FileInfo{
+		{Path: "migrations/001.sql"}, {Path: "migrations/002.sql"}, {Path: "migrations/003.sql"},
+		{Path: "main.go"},
+	}
+
+	warnCappedDirs(2, files, zap.New(core))
+
+	if logs.Len() != 1 {
+		t.Fatalf("Expected one warning, got %d", logs.Len())
+	}
+
+	if dir := logs.All()[0].ContextMap()["dir"]; dir != "migrations" {
+		t.Errorf("Expected a warning for migrations, got %v", dir)
+	}
+}
+
+func TestRunCode2MD_CappedDirsWarnedForMarkdownOnly(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      func(dir string) *config.Config
+		warnings int
+	}{
+		{"markdown", func(dir string) *config.Config {
+			return &config.Config{OutputFile: filepath.Join(dir, "out.md")}
+		}, 1},
+		{"json", func(dir string) *config.Config {
+			return &config.Config{OutputFile: filepath.Join(dir, "out.json"), Formats: []string{config.FormatJSON}}
+		}, 0},
+		{"output dir", func(dir string) *config.Config {
+			return &config.Config{OutputDir: filepath.Join(dir, "out")}
+		}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			projDir := t.TempDir()
+			for _, name := range []string{"a.go", "b.go", "c.go"} {
+				if err := os.WriteFile(filepath.Join(projDir, name), []byte("package p\n"), 0o600); err != nil {
+					t.Fatalf("Failed to write %s: %v", name, err)
+				}
+			}
+
+			cfg := tc.cfg(t.TempDir())
+			cfg.MaxFileSize = 1024
+			cfg.MaxFilesPerDir = 2
+
+			core, logs := observer.New(zapcore.WarnLevel)
+			if err := runCode2MD(context.Background(), cfg, zap.New(core), []string{projDir}); err != nil {
+				t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+			}
+
+			if got := logs.FilterMessage("Directory exceeds --max-files-per-dir; extra files are omitted").Len(); got != tc.warnings {
+				t.Errorf("Expected %d capped-directory warnings, got %d", tc.warnings, got)
+			}
+		})
+	}
+}
+
+func TestCreateRootCommand_SinceRef(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	for _, tc := range []struct {
+		args     []string
+		since    string
+		expected error
+	}{
+		{[]string{"--since=main", "--dry-run", "."}, "main", nil},
+		{[]string{"--since", "main"}, config.SinceDefaultBranch, errSinceRefAsDirectory},
+	} {
+		cfg := config.NewConfig()
+
+		cmd := createRootCommand(cfg, zap.NewNop())
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(tc.args)
+
+		// Outside a git repository, --since=main parses but cannot resolve the ref.
+		if err := cmd.Execute(); tc.expected != nil && !errors.Is(err, tc.expected) {
+			t.Errorf("Execute(%v): expected %v, got %v", tc.args, tc.expected, err)
+		}
+
+		if cfg.Since != tc.since {
+			t.Errorf("Execute(%v): expected Since %q, got %q", tc.args, tc.since, cfg.Since)
+		}
+	}
+}
+
+func TestRunCode2MD_SinceOutput(t *testing.T) {
+	projDir := t.TempDir()
+	writeProjectFile := func(name, content string) {
+		t.Helper()
+
+		if err := os.WriteFile(filepath.Join(projDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	// A heading inside file content is not a section of the output.
+	writeProjectFile("a.go", "package a\n")
+	writeProjectFile("README.md", "# Readme\n\n### c.go\n")
+
+	outDir := t.TempDir()
+	previous := filepath.Join(outDir, "previous.md")
+
+	cfg := &config.Config{OutputFile: previous, MaxFileSize: 1024 * 1024}
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	writeProjectFile("b.go", "package b\n")
+	writeProjectFile("c.go", "package c\n")
+
+	cfg = &config.Config{OutputFile: filepath.Join(outDir, "new.md"), MaxFileSize: 1024 * 1024, SinceOutput: previous}
+	if err := runCode2MD(context.Background(), cfg, zap.NewNop(), []string{projDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read the output: %v", err)
+	}
+
+	output := string(data)
+	for _, path := range []string{"b.go", "c.go"} {
+		if !strings.Contains(output, "### "+path+"\n") {
+			t.Errorf("Expected a section for new file %s, got:\n%s", path, output)
+		}
+	}
+
+	for _, path := range []string{"a.go", "README.md"} {
+		if strings.Contains(output, "### "+path+"\n") {
+			t.Errorf("Expected no section for previously output file %s, got:\n%s", path, output)
+		}
+	}
+}
+
+func TestPreviousOutputFiles(t *testing.T) {
+	previous := "## File Contents\n\n" +
+		"### notes.md\n\n**Size:** 40 B  \n**Path:** `notes.md`  \n\n" +
+		"```markdown\n```go\n### fake.go\n\n**Size:** 1 B  \n```\n``` unbalanced\n```\n\n" +
+		"### a.go\n\n**Size:** 10 B  \n**Relative Size:** `####`  \n**Path:** `a.go`  \n\n```go\npackage a\n```\n\n" +
+		"### secret.txt\n\n**Size:** 8 B  \n**Path:** `secret.txt`  \n\n_(content omitted)_\n\n"
+
+	paths, err := previousOutputFiles(strings.NewReader(previous))
+	if err != nil {
+		t.Fatalf("previousOutputFiles() returned an unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"notes.md": true, "a.go": true, "secret.txt": true}
+	if !maps.Equal(paths, expected) {
+		t.Errorf("Expected sections %v, got %v", expected, paths)
+	}
+}
+
+func TestRunCode2MD_VerboseExtensionStats(t *testing.T) {
+	projDir := t.TempDir()
+	projectFiles := map[string]string{
+		".gitignore":   "*.tmp.go\n",
+		"main.go":      "package main\n",
+		"util.go":      "package main\n",
+		"draft.tmp.go": "package main\n",
+		"big.go":       "package main\n\n// " + strings.Repeat("x", 200) + "\n",
+		"image.txt":    "\x00\x01\x02binary",
+		"notes.txt":    "notes\n",
+	}
+
+	for name, content := range projectFiles {
+		if err := os.WriteFile(filepath.Join(projDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	cfg := &config.Config{OutputFile: filepath.Join(t.TempDir(), "out.md"), MaxFileSize: 100, Verbose: true}
+
+	if err := runCode2MD(context.Background(), cfg, zap.New(core), []string{projDir}); err != nil {
+		t.Fatalf("runCode2MD returned an unexpected error: %v", err)
+	}
+
+	got := make(map[string]map[string]any)
+	for _, entry := range logs.FilterMessage("Extension statistics").All() {
+		fields := entry.ContextMap()
+		got[fields["extension"].(string)] = fields
+	}
+
+	expected := map[string]map[string]int64{
+		".go":  {"included": 2, "too_large": 1, "binary": 0, "gitignored": 1},
+		".txt": {"included": 1, "too_large": 0, "binary": 1, "gitignored": 0},
+	}
+
+	for ext, counts := range expected {
+		for field, want := range counts {
+			if got[ext][field] != want {
+				t.Errorf("%s %s = %v, want %d", ext, field, got[ext][field], want)
+			}
+		}
+	}
+}
+
+func TestWarnLargeOutputs(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.md")
+	large := filepath.Join(dir, "large.md")
+
+	if err := os.WriteFile(small, []byte("# small\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", small, err)
+	}
+
+	if err := os.WriteFile(large, bytes.Repeat([]byte("x"), 2048), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", large, err)
+	}
+
+	var buf bytes.Buffer
+
+	warnLargeOutputs(&buf, 1024, []string{small, large})
+
+	if got := buf.String(); !strings.Contains(got, "Output file "+large+" is 2.0 KB") || strings.Contains(got, small) {
+		t.Errorf("Expected a single warning for %s, got %q", large, got)
+	}
+
+	buf.Reset()
+	warnLargeOutputs(&buf, 0, []string{large})
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected no warning with the check disabled, got %q", buf.String())
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// dryRunReport is the dry-run file list written with --format json.
+type dryRunReport struct {
+	Files     []dryRunFile `json:"files"`
+	Count     int          `json:"count"`
+	TotalSize int64        `json:"total_size"`
+}
+
+// dryRunFile describes one file that would be included in the output.
+type dryRunFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Language string `json:"language"`
+}
+
+// printDryRun writes the files that would be included, sorted by path: as JSON when
+// the json format is selected, and as a plain list otherwise.
+func printDryRun(w io.Writer, cfg *config.Config, files []gatherer.FileInfo) error {
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b gatherer.FileInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	if slices.Contains(cfg.Formats, config.FormatJSON) {
+		report := dryRunReport{Files: make([]dryRunFile, len(sorted)), Count: len(sorted)}
+		for i, file := range sorted {
+			report.Files[i] = dryRunFile{Path: file.Path, Size: file.Size, Language: file.Language}
+			report.TotalSize += file.Size
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(report)
+	}
+
+	if _, err := fmt.Fprintln(w, "Dry Run: The following files would be included in the output:"); err != nil {
+		return err
+	}
+
+	for _, file := range sorted {
+		if _, err := fmt.Fprintln(w, file.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
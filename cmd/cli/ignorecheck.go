@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// newIgnoreCheckCommand creates the ignore-check subcommand, which lists the paths
+// excluded by ignore files together with the rule that excluded each one. Its flags are
+// bound to flags, like those of the root command.
+func newIgnoreCheckCommand(cfg, flags *config.Config, logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ignore-check [directory]",
+		Short: "List the paths excluded by ignore files and the rule that caught each",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targetDir := "."
+			if len(args) > 0 {
+				targetDir = args[0]
+			}
+
+			absPath, err := filepath.Abs(targetDir)
+			if err != nil {
+				return fmt.Errorf("error resolving path: %w", err)
+			}
+
+			ignored, err := gatherer.NewFileGatherer(cfg, absPath, logger).IgnoredPaths(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("error walking %s: %w", absPath, err)
+			}
+
+			return writeIgnoreCheck(cmd, ignored)
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.NoGitInfoExclude, "no-git-info-exclude", flags.NoGitInfoExclude,
+		"Do not apply the repository-local ignore patterns in .git/info/exclude")
+	cmd.Flags().BoolVar(&flags.NpmIgnore, "npm-ignore", flags.NpmIgnore, "Also apply patterns from .npmignore")
+	cmd.Flags().StringSliceVar(&flags.IgnoreFiles, "ignore-file", flags.IgnoreFiles,
+		"Gitignore-syntax file whose patterns exclude files, e.g. .eslintignore (repeatable)")
+
+	return cmd
+}
+
+// writeIgnoreCheck prints the ignored paths as a markdown table.
+func writeIgnoreCheck(cmd *cobra.Command, ignored []gatherer.IgnoredPath) error {
+	out := cmd.OutOrStdout()
+
+	if len(ignored) == 0 {
+		_, err := fmt.Fprintln(out, "No paths are ignored.")
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "| Path | Ignore File | Rule |\n| --- | --- | --- |\n"); err != nil {
+		return err
+	}
+
+	cell := strings.NewReplacer("|", `\|`)
+
+	for _, path := range ignored {
+		if _, err := fmt.Fprintf(out, "| %s | %s | `%s` |\n",
+			cell.Replace(path.Path), cell.Replace(path.Source), cell.Replace(path.Pattern)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
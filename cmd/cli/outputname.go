@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errUnknownPlaceholder = errors.New("unknown output file placeholder")
+
+// outputPlaceholder matches a {name} placeholder in the output file name.
+var outputPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// outputNameVars holds the values substituted for the output file placeholders.
+type outputNameVars struct {
+	repo  string
+	count int
+	now   time.Time
+
+	keepCount bool // Leave {count} in place; it is only known after gathering.
+}
+
+// expandOutputName replaces the {date}, {time}, {repo} and {count} placeholders in name.
+func expandOutputName(name string, vars outputNameVars) (string, error) {
+	var err error
+
+	expanded := outputPlaceholder.ReplaceAllStringFunc(name, func(placeholder string) string {
+		switch placeholder {
+		case "{date}":
+			return vars.now.Format("2006-01-02")
+		case "{time}":
+			return vars.now.Format("150405")
+		case "{repo}":
+			return vars.repo
+		case "{count}":
+			if vars.keepCount {
+				return placeholder
+			}
+
+			return strconv.Itoa(vars.count)
+		default:
+			if err == nil {
+				err = fmt.Errorf("%w: %s (supported: {date}, {time}, {repo}, {count})", errUnknownPlaceholder, placeholder)
+			}
+
+			return placeholder
+		}
+	})
+
+	return expanded, err
+}
+
+// outputNameGlob returns a filepath.Match pattern for the output file name in which each
+// placeholder matches any text, so that it also matches the outputs of earlier runs.
+func outputNameGlob(name string) string {
+	var glob strings.Builder
+
+	last := 0
+	for _, loc := range outputPlaceholder.FindAllStringIndex(name, -1) {
+		glob.WriteString(escapeGlob(name[last:loc[0]]) + "*")
+		last = loc[1]
+	}
+
+	glob.WriteString(escapeGlob(name[last:]))
+
+	return glob.String()
+}
+
+// escapeGlob escapes the filepath.Match metacharacters in s. A backslash is only a
+// metacharacter where it is not the path separator.
+func escapeGlob(s string) string {
+	replacements := []string{"*", "[*]", "?", "[?]", "[", "[[]"}
+	if filepath.Separator != '\\' {
+		replacements = append(replacements, "\\", "\\\\")
+	}
+
+	return strings.NewReplacer(replacements...).Replace(s)
+}
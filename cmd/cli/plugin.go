@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"code2md/internal/generator"
+	"code2md/pkg/plugin"
+	"errors"
+	"fmt"
+	"io"
+	goplugin "plugin"
+	"runtime"
+
+	"go.uber.org/zap"
+)
+
+var errInvalidPlugin = errors.New("invalid plugin")
+
+// loadPlugins opens each Go plugin and returns its generator keyed by the format name it
+// registers. A plugin implements the interface of package code2md/pkg/plugin.
+func loadPlugins(paths []string, logger *zap.Logger) (map[string]generator.Generator, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	if runtime.GOOS != "linux" {
+		logger.Warn("Go plugins are only reliably supported on Linux and require a CGO-enabled build",
+			zap.String("os", runtime.GOOS))
+	}
+
+	plugins := make(map[string]generator.Generator, len(paths))
+
+	for _, path := range paths {
+		name, gen, err := loadPlugin(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, taken := formatExtension(name, plugins); taken {
+			return nil, fmt.Errorf("%w: %s: format %q is already registered", errInvalidPlugin, path, name)
+		}
+
+		plugins[name] = gen
+	}
+
+	return plugins, nil
+}
+
+// loadPlugin opens the plugin at path and returns its format name and generator.
+func loadPlugin(path string) (string, generator.Generator, error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+
+	return pluginSymbols(path, p.Lookup)
+}
+
+// pluginSymbols looks up and checks the symbols of the plugin at path.
+func pluginSymbols(path string, lookup func(string) (goplugin.Symbol, error)) (string, generator.Generator, error) {
+	genSymbol, err := lookup(plugin.GeneratorSymbol)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %w", errInvalidPlugin, path, err)
+	}
+
+	gen, ok := genSymbol.(*plugin.Generator)
+	if !ok || gen == nil || *gen == nil {
+		return "", nil, fmt.Errorf("%w: %s: %s must be a non-nil plugin.Generator variable", errInvalidPlugin, path, plugin.GeneratorSymbol)
+	}
+
+	nameSymbol, err := lookup(plugin.NameSymbol)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %w", errInvalidPlugin, path, err)
+	}
+
+	pluginName, ok := nameSymbol.(func() string)
+	if !ok || pluginName() == "" {
+		return "", nil, fmt.Errorf("%w: %s: %s must be a func() string returning a format name", errInvalidPlugin, path, plugin.NameSymbol)
+	}
+
+	return pluginName(), pluginGenerator{Generator: *gen}, nil
+}
+
+// pluginGenerator adapts a plugin's generator to the generators of the built-in formats.
+type pluginGenerator struct {
+	plugin.Generator
+	noContent gatherer.GlobSet
+}
+
+// Generate passes the files to the plugin as plugin.File values, without the content of
+// the files that match noContent.
+func (pg pluginGenerator) Generate(w io.Writer, files []gatherer.FileInfo, rootPath string) error {
+	pluginFiles := make([]plugin.File, len(files))
+	for i, file := range files {
+		pluginFiles[i] = plugin.File{Path: file.Path, Language: file.Language, Size: file.Size, ModTime: file.ModTime}
+
+		if pg.noContent.Match(file.Path) {
+			pluginFiles[i].Omitted = true
+		} else {
+			pluginFiles[i].Content = file.Text()
+		}
+	}
+
+	return pg.Generator.Generate(w, pluginFiles, rootPath)
+}
+
+// withNoContent applies the NoContentFor patterns of cfg to gen when it is a plugin's generator.
+func withNoContent(gen generator.Generator, cfg *config.Config) (generator.Generator, error) {
+	pg, ok := gen.(pluginGenerator)
+	if !ok {
+		return gen, nil
+	}
+
+	noContent, err := gatherer.CompileGlobSet(cfg.NoContentFor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --no-content-for pattern: %w", err)
+	}
+
+	pg.noContent = noContent
+
+	return pg, nil
+}
+
+// formatExtension returns the output file extension of a built-in or plugin format,
+// reporting whether the format is known. Plugin formats use their name as extension.
+func formatExtension(format string, plugins map[string]generator.Generator) (string, bool) {
+	if ext, ok := formatExtensions()[format]; ok {
+		return ext, true
+	}
+
+	if _, ok := plugins[format]; ok {
+		return "." + format, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"code2md/internal/config"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// flagConfigKeys maps flags whose names do not match their config key
+// (with dashes replaced by underscores) to that key.
+func flagConfigKeys() map[string]string {
+	return map[string]string{
+		"output":       "output_file",
+		"include":      "include_ext",
+		"exclude":      "exclude_ext",
+		"hidden":       "include_hidden",
+		"ignore-file":  "ignore_files",
+		"template-var": "template_vars",
+		"plugin":       "plugins",
+	}
+}
+
+// flagConfigKey returns the configuration key set by the named flag.
+func flagConfigKey(name string) string {
+	if key, ok := flagConfigKeys()[name]; ok {
+		return key
+	}
+
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// profileExcludedKeys are settings that only make sense for a single invocation and are
+// therefore left out of generated profiles.
+func profileExcludedKeys() map[string]bool {
+	return map[string]bool{"print_config": true, "generate_profile": true, "json_schema": true, "dry_run": true}
+}
+
+// printConfig writes the effective configuration as YAML to the command's output,
+// annotating each value with where it came from (flag, env, profile, or default).
+func printConfig(cmd *cobra.Command, cfg *config.Config) error {
+	doc, err := annotatedConfig(cmd, cfg)
+	if err != nil {
+		return err
+	}
+
+	return encodeYAML(cmd, doc)
+}
+
+// generateProfile writes the effective configuration as a config.FileName document with
+// a single default profile, annotated like printConfig, so that the combination of
+// environment, profile, and flags can be saved and reproduced.
+func generateProfile(cmd *cobra.Command, cfg *config.Config) error {
+	doc, err := annotatedConfig(cmd, cfg)
+	if err != nil {
+		return err
+	}
+
+	settings := &yaml.Node{Kind: yaml.MappingNode}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if !profileExcludedKeys()[doc.Content[i].Value] {
+			settings.Content = append(settings.Content, doc.Content[i], doc.Content[i+1])
+		}
+	}
+
+	file := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "profiles"},
+		{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: config.DefaultProfileName},
+			settings,
+		}},
+	}}
+
+	return encodeYAML(cmd, file)
+}
+
+// annotatedConfig encodes cfg as a YAML mapping whose entries carry their source as a line comment.
+func annotatedConfig(cmd *cobra.Command, cfg *config.Config) (*yaml.Node, error) {
+	sources, err := config.Sources()
+	if err != nil {
+		return nil, fmt.Errorf("error resolving configuration sources: %w", err)
+	}
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		sources[flagConfigKey(f.Name)] = config.SourceFlag
+	})
+
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("error encoding configuration: %w", err)
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+
+		// Block collections carry the comment on their key; scalars and empty collections on the value.
+		if value.Kind != yaml.ScalarNode && len(value.Content) > 0 {
+			key.LineComment = sources[key.Value]
+		} else {
+			value.LineComment = sources[key.Value]
+		}
+	}
+
+	return &doc, nil
+}
+
+// encodeYAML writes doc to the command's output with the indentation used by config.FileName.
+func encodeYAML(cmd *cobra.Command, doc *yaml.Node) error {
+	encoder := yaml.NewEncoder(cmd.OutOrStdout())
+	encoder.SetIndent(2) //nolint:mnd // Two-space indentation matches .code2md.yaml.
+
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
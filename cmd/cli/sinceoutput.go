@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"code2md/internal/gatherer"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// previousOutputFiles returns the paths of the file sections of a previous markdown
+// output. A "### path" heading counts as a section only when its metadata block has the
+// matching "**Path:**" line, so headings and fences inside file content are ignored.
+func previousOutputFiles(r io.Reader) (map[string]bool, error) {
+	paths := make(map[string]bool)
+
+	// heading is the path of the last heading while its metadata block is being read.
+	var heading string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "### "):
+			heading = strings.TrimPrefix(line, "### ")
+		case line == "":
+		case heading != "" && strings.HasPrefix(line, "**"):
+			if path, ok := strings.CutPrefix(strings.TrimSpace(line), "**Path:** `"); ok && strings.TrimSuffix(path, "`") == heading {
+				paths[heading] = true
+				heading = ""
+			}
+		default:
+			heading = ""
+		}
+	}
+
+	return paths, scanner.Err()
+}
+
+// dropPreviousFiles removes the files already present in the previous output at path,
+// so that only files new since that output remain.
+func dropPreviousFiles(path string, files []gatherer.FileInfo) ([]gatherer.FileInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading --since-output: %w", err)
+	}
+
+	previous, err := previousOutputFiles(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading --since-output: %w", err)
+	}
+
+	return slices.DeleteFunc(files, func(file gatherer.FileInfo) bool { return previous[file.Path] }), nil
+}
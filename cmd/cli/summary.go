@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"code2md/internal/gatherer"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// ciSummary is the machine-readable run summary written to stderr with --ci-output.
+type ciSummary struct {
+	Files      int      `json:"files"`
+	Skipped    int      `json:"skipped"`
+	TotalBytes int64    `json:"total_bytes"`
+	OutputPath string   `json:"output_path"`
+	DurationMS int64    `json:"duration_ms"`
+	Errors     []string `json:"errors"`
+}
+
+// recordFiles stores the gathering results. It is a no-op on a nil summary.
+func (s *ciSummary) recordFiles(files []gatherer.FileInfo, stats gatherer.GatherStats) {
+	if s == nil {
+		return
+	}
+
+	s.Files = len(files)
+	s.Skipped = stats.SkippedFiles
+
+	for _, file := range files {
+		s.TotalBytes += file.Size
+	}
+}
+
+// recordOutputs stores the written output paths. It is a no-op on a nil summary.
+func (s *ciSummary) recordOutputs(outputs ...string) {
+	if s == nil {
+		return
+	}
+
+	s.OutputPath = strings.Join(outputs, ",")
+}
+
+// write finalizes the summary with the run's duration and error, then encodes it as one JSON line.
+func (s *ciSummary) write(w io.Writer, start time.Time, runErr error) error {
+	s.DurationMS = time.Since(start).Milliseconds()
+	if runErr != nil {
+		s.Errors = append(s.Errors, runErr.Error())
+	}
+
+	return json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,39 @@
+// Command plugin-example is a minimal code2md output format plugin. It writes one line
+// per gathered file with the file's path and size. Build it from the root of this module,
+// so that it shares the exact package versions of the code2md binary, and load it with
+// --plugin. It only imports code2md/pkg/plugin:
+//
+//	go build -buildmode=plugin -o filelist.so ./cmd/plugin-example
+//	code2md --plugin filelist.so --format filelist
+package main
+
+import (
+	"code2md/pkg/plugin"
+	"fmt"
+	"io"
+)
+
+// Generator is the output generator that code2md looks up in the plugin.
+var Generator plugin.Generator = fileListGenerator{} //nolint:gochecknoglobals // Plugin symbols must be package variables.
+
+// PluginName returns the format name that selects this plugin with --format.
+func PluginName() string {
+	return "filelist"
+}
+
+// fileListGenerator writes the path and size of every gathered file, one per line.
+type fileListGenerator struct{}
+
+// Generate writes the file list to w.
+func (fileListGenerator) Generate(w io.Writer, files []plugin.File, _ string) error {
+	for _, file := range files {
+		if _, err := fmt.Fprintf(w, "%s\t%d\n", file.Path, file.Size); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// main is unused; plugins are loaded with -buildmode=plugin, but go build ./... needs it.
+func main() {}
@@ -1,33 +1,188 @@
 package config
 
 import (
+	"os"
+	"slices"
+	"sync"
+
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 )
 
 // Config holds all the configuration for the application.
 type Config struct {
-	OutputFile    string   `envconfig:"OUTPUT_FILE"`
-	IncludeExt    []string `envconfig:"INCLUDE_EXT"`
-	ExcludeExt    []string `envconfig:"EXCLUDE_EXT"`
-	ExcludeDirs   []string `envconfig:"EXCLUDE_DIRS"`
-	MaxFileSize   int64    `envconfig:"MAX_SIZE"`
-	IncludeHidden bool     `envconfig:"INCLUDE_HIDDEN"`
-	Verbose       bool     `envconfig:"VERBOSE"`
-	DryRun        bool     `envconfig:"DRY_RUN"`
+	OutputFile              string            `envconfig:"OUTPUT_FILE" yaml:"output_file"`
+	RepoName                string            `envconfig:"REPO_NAME" yaml:"repo_name"`
+	OutputDir               string            `envconfig:"OUTPUT_DIR" yaml:"output_dir"`
+	IncludeExt              []string          `envconfig:"INCLUDE_EXT" yaml:"include_ext"`
+	ExcludeExt              []string          `envconfig:"EXCLUDE_EXT" yaml:"exclude_ext"`
+	ExcludeDirs             []string          `envconfig:"EXCLUDE_DIRS" yaml:"exclude_dirs"`
+	MaxFileSize             int64             `envconfig:"MAX_SIZE" yaml:"max_size"`
+	PriorityFiles           []string          `envconfig:"PRIORITY_FILES" yaml:"priority_files"`
+	MaxFileTokens           int               `envconfig:"MAX_FILE_TOKENS" yaml:"max_file_tokens"`
+	MaxFiles                int               `envconfig:"MAX_FILES" yaml:"max_files"`
+	SkipWhitespaceOnly      bool              `envconfig:"SKIP_WHITESPACE_ONLY" yaml:"skip_whitespace_only"`
+	FailOnLargeFile         bool              `envconfig:"FAIL_ON_LARGE_FILE" yaml:"fail_on_large_file"`
+	FailOnEmpty             bool              `envconfig:"FAIL_ON_EMPTY" yaml:"fail_on_empty"`
+	IncludeHidden           bool              `envconfig:"INCLUDE_HIDDEN" yaml:"include_hidden"`
+	SkipHiddenDirs          bool              `envconfig:"SKIP_HIDDEN_DIRS" yaml:"skip_hidden_dirs"`
+	RelativizeSymlinks      bool              `envconfig:"RELATIVIZE_SYMLINKS" yaml:"relativize_symlinks"`
+	RelativeTo              string            `envconfig:"RELATIVE_TO" yaml:"relative_to"`
+	StripPrefix             string            `envconfig:"STRIP_PREFIX" yaml:"strip_prefix"`
+	Verbose                 bool              `envconfig:"VERBOSE" yaml:"verbose"`
+	DryRun                  bool              `envconfig:"DRY_RUN" yaml:"dry_run"`
+	CIOutput                bool              `envconfig:"CI_OUTPUT" yaml:"ci_output"`
+	SuccessMessage          string            `envconfig:"SUCCESS_MESSAGE" yaml:"success_message"`
+	Quiet                   bool              `envconfig:"QUIET" yaml:"quiet"`
+	PrintConfig             bool              `envconfig:"PRINT_CONFIG" yaml:"print_config"`
+	GenerateProfile         bool              `envconfig:"GENERATE_PROFILE" yaml:"generate_profile"`
+	JSONSchema              bool              `envconfig:"JSON_SCHEMA" yaml:"json_schema"`
+	NoContentFor            []string          `envconfig:"NO_CONTENT_FOR" yaml:"no_content_for"`
+	Summarize               []string          `envconfig:"SUMMARIZE" yaml:"summarize"`
+	NoTOC                   bool              `envconfig:"NO_TOC" yaml:"no_toc"`
+	TOCTable                bool              `envconfig:"TOC_TABLE" yaml:"toc_table"`
+	TOCHierarchy            bool              `envconfig:"TOC_HIERARCHY" yaml:"toc_hierarchy"`
+	NoHeader                bool              `envconfig:"NO_HEADER" yaml:"no_header"`
+	Chart                   bool              `envconfig:"CHART" yaml:"chart"`
+	RelativeSizeBar         bool              `envconfig:"RELATIVE_SIZE_BAR" yaml:"relative_size_bar"`
+	AnnotateImports         bool              `envconfig:"ANNOTATE_IMPORTS" yaml:"annotate_imports"`
+	RelativeAnchorIDs       bool              `envconfig:"RELATIVE_ANCHOR_IDS" yaml:"relative_anchor_ids"`
+	GroupBy                 string            `envconfig:"GROUP_BY" yaml:"group_by"`
+	GroupByDir              bool              `envconfig:"GROUP_BY_DIR" yaml:"group_by_dir"`
+	Sort                    string            `envconfig:"SORT" yaml:"sort"`
+	IncludeDirReadmeContext bool              `envconfig:"INCLUDE_DIR_README_CONTEXT" yaml:"include_dir_readme_context"`
+	MaxFilesPerDir          int               `envconfig:"MAX_FILES_PER_DIR" yaml:"max_files_per_dir"`
+	SplitSize               int64             `envconfig:"SPLIT_SIZE" yaml:"split_size"`
+	SplitByDir              bool              `envconfig:"SPLIT_BY_DIR" yaml:"split_by_dir"`
+	WarnOutputSize          int64             `envconfig:"WARN_OUTPUT_SIZE" yaml:"warn_output_size"`
+	BOM                     bool              `envconfig:"BOM" yaml:"bom"`
+	ExcludePatterns         []string          `envconfig:"EXCLUDE_PATTERNS" yaml:"exclude_patterns"`
+	ExcludeGenerated        bool              `envconfig:"EXCLUDE_GENERATED" yaml:"exclude_generated"`
+	ExcludeTests            bool              `envconfig:"EXCLUDE_TESTS" yaml:"exclude_tests"`
+	TestsOnly               bool              `envconfig:"TESTS_ONLY" yaml:"tests_only"`
+	WrapWidth               int               `envconfig:"WRAP_WIDTH" yaml:"wrap_width"`
+	TrimTrailingWhitespace  bool              `envconfig:"TRIM_TRAILING_WHITESPACE" yaml:"trim_trailing_whitespace"`
+	StripBlankLines         bool              `envconfig:"STRIP_BLANK_LINES" yaml:"strip_blank_lines"`
+	NpmIgnore               bool              `envconfig:"NPM_IGNORE" yaml:"npm_ignore"`
+	NoGitInfoExclude        bool              `envconfig:"NO_GIT_INFO_EXCLUDE" yaml:"no_git_info_exclude"`
+	NpmOnly                 bool              `envconfig:"NPM_ONLY" yaml:"npm_only"`
+	IgnoreFiles             []string          `envconfig:"IGNORE_FILES" yaml:"ignore_files"`
+	ShowIgnoreRules         bool              `envconfig:"SHOW_IGNORE_RULES" yaml:"show_ignore_rules"`
+	MaxReadBytesPerSec      int64             `envconfig:"MAX_READ_BYTES_PER_SEC" yaml:"max_read_bytes_per_sec"`
+	MaxMemory               int64             `envconfig:"MAX_MEMORY" yaml:"max_memory"`
+	SinceTag                string            `envconfig:"SINCE_TAG" yaml:"since_tag"`
+	Since                   string            `envconfig:"SINCE" yaml:"since"`
+	SinceOutput             string            `envconfig:"SINCE_OUTPUT" yaml:"since_output"`
+	StdinFile               string            `envconfig:"STDIN_FILE" yaml:"stdin_file"`
+	KeepLockfiles           bool              `envconfig:"KEEP_LOCKFILES" yaml:"keep_lockfiles"`
+	OutputEncoding          string            `envconfig:"OUTPUT_ENCODING" yaml:"output_encoding"`
+	NoDefaultExcludes       bool              `envconfig:"NO_DEFAULT_EXCLUDES" yaml:"no_default_excludes"`
+	NoDefaultExcludeDirs    bool              `envconfig:"NO_DEFAULT_EXCLUDE_DIRS" yaml:"no_default_exclude_dirs"`
+	Languages               []string          `envconfig:"LANGUAGES" yaml:"languages"`
+	SeedPrompt              string            `envconfig:"SEED_PROMPT" yaml:"seed_prompt"`
+	ContentPrefix           string            `envconfig:"CONTENT_PREFIX" yaml:"content_prefix"`
+	ContentPrefixFile       string            `envconfig:"CONTENT_PREFIX_FILE" yaml:"content_prefix_file"`
+	ContentSuffix           string            `envconfig:"CONTENT_SUFFIX" yaml:"content_suffix"`
+	ContentSuffixFile       string            `envconfig:"CONTENT_SUFFIX_FILE" yaml:"content_suffix_file"`
+	Grep                    string            `envconfig:"GREP" yaml:"grep"`
+	PreserveNoFinalNewline  bool              `envconfig:"PRESERVE_NO_FINAL_NEWLINE" yaml:"preserve_no_final_newline"`
+	Template                string            `envconfig:"TEMPLATE" yaml:"template"`
+	TemplateVars            map[string]string `envconfig:"TEMPLATE_VARS" yaml:"template_vars"`
+	Formats                 []string          `envconfig:"FORMAT" yaml:"format"`
+	Plugins                 []string          `envconfig:"PLUGINS" yaml:"plugins"`
+	ChunkTokens             int               `envconfig:"CHUNK_TOKENS" yaml:"chunk_tokens"`
 }
 
-// DefaultExtensions returns the default list of source code extensions.
-func DefaultExtensions() []string {
-	return []string{
+// Supported values for Config.Formats. An empty list means FormatMarkdown.
+const (
+	FormatMarkdown  = "markdown"
+	FormatJSON      = "json"
+	FormatJSONL     = "jsonl"
+	FormatLLMChunks = "llm-chunks"
+)
+
+// SinceDefaultBranch is the Config.Since value that compares against the repository's
+// default branch; it is also the value of a bare --since flag.
+const SinceDefaultBranch = "default-branch"
+
+// Supported values for Config.Sort. An empty value sorts by path.
+const (
+	SortPath     = "path"
+	SortLanguage = "language"
+)
+
+// Supported values for Config.GroupBy. An empty value means no grouping.
+const (
+	GroupByDirectory = "directory"
+	GroupByTopLevel  = "top-level"
+)
+
+// Built-in defaults for numeric settings, applied by NewConfig.
+const (
+	DefaultMaxFileSize    = 1024 * 1024      // 1MB
+	MaxPriorityFileSize   = 10 * 1024 * 1024 // Size cap for PriorityFiles, which bypass MaxFileSize.
+	DefaultChunkTokens    = 2000
+	DefaultWarnOutputSize = 10 * 1024 * 1024 // 10MB
+)
+
+// DefaultSuccessMessage is the format string printed after a successful run. It receives
+// the output path, file count, total input bytes, duration, formatted output size, and
+// estimated output tokens, in that order; the last two only go to templates with explicit
+// argument indexes.
+const DefaultSuccessMessage = "Successfully generated %[1]s (%[2]d files, %[5]s, ~%[6]s tokens)"
+
+// Supported values for Config.OutputEncoding. An empty value means EncodingUTF8.
+const (
+	EncodingUTF8    = "utf8"
+	EncodingUTF8BOM = "utf8-bom"
+)
+
+//nolint:gochecknoglobals // Registry of default extensions that library users may customize at init time.
+var (
+	defaultExtensionsMu sync.RWMutex
+	defaultExtensions   = []string{
 		".go", ".py", ".js", ".ts", ".java", ".c", ".cpp", ".h", ".hpp",
 		".cs", ".php", ".rb", ".rs", ".swift", ".kt", ".scala", ".sh",
 		".sql", ".html", ".css", ".scss", ".less", ".vue", ".jsx", ".tsx",
 		".yaml", ".yml", ".json", ".xml", ".toml", ".ini", ".cfg", ".conf",
 		".md", ".txt", ".rst", ".dockerfile", "Dockerfile", "Makefile",
+		".lua", ".pl", ".pm", ".cob", ".cbl", ".f90", ".f95", ".for", ".adb", ".ads",
+		".env.example", ".env.template", ".env.local", ".env.development",
+		".bicep", ".bicepparam", "Jenkinsfile", ".gitlab-ci.yml",
+		".smk", ".snakemake", "Snakefile", ".nf", "nextflow.config",
+		".proto", ".graphql", ".gql", ".thrift", ".avsc", ".prisma",
+	}
+)
+
+// DefaultExtensions returns a copy of the default list of source code extensions.
+func DefaultExtensions() []string {
+	defaultExtensionsMu.RLock()
+	defer defaultExtensionsMu.RUnlock()
+
+	return slices.Clone(defaultExtensions)
+}
+
+// RegisterDefaultExtension adds an extension (or exact file name) to the default list.
+// Registering an extension that is already present is a no-op.
+func RegisterDefaultExtension(ext string) {
+	defaultExtensionsMu.Lock()
+	defer defaultExtensionsMu.Unlock()
+
+	if !slices.Contains(defaultExtensions, ext) {
+		defaultExtensions = append(defaultExtensions, ext)
 	}
 }
 
+// UnregisterDefaultExtension removes an extension (or exact file name) from the default list.
+func UnregisterDefaultExtension(ext string) {
+	defaultExtensionsMu.Lock()
+	defer defaultExtensionsMu.Unlock()
+
+	defaultExtensions = slices.DeleteFunc(defaultExtensions, func(e string) bool {
+		return e == ext
+	})
+}
+
 // DefaultExcludeDirs returns the comprehensive default list of directories to exclude.
 // This list is used when a .gitignore file is NOT found in the repository.
 func DefaultExcludeDirs() []string {
@@ -39,25 +194,89 @@ func DefaultExcludeDirs() []string {
 	}
 }
 
-// DefaultExcludeFiles returns the default list of specific files to exclude.
-func DefaultExcludeFiles() []string {
+// DefaultHiddenDirs returns the hidden directories that are walked even when hidden
+// files are excluded, because they hold project configuration such as CI workflows.
+func DefaultHiddenDirs() []string {
+	return []string{".github"}
+}
+
+// DefaultLockfiles returns the package manager lockfiles excluded by default.
+// They are matched by exact file name and can be kept with KeepLockfiles.
+func DefaultLockfiles() []string {
 	return []string{
 		"pnpm-lock.yaml",
 		"bun.lockb",
-		"codebase.md",
+		"package-lock.json",
+		"yarn.lock",
+		"Cargo.lock",
+		"poetry.lock",
+		"Gemfile.lock",
+		"go.sum",
+		"composer.lock",
+		"mix.lock",
+		"Pipfile.lock",
+	}
+}
+
+// DefaultPriorityFiles returns the dependency manifests that are always gathered and
+// placed first in the output. They are matched by exact file name in any directory.
+func DefaultPriorityFiles() []string {
+	return []string{
+		"go.mod",
+		"package.json",
+		"requirements.txt",
+		"Cargo.toml",
+		"pyproject.toml",
+		"composer.json",
+	}
+}
+
+// DefaultExcludeFiles returns the default list of specific files to exclude.
+func DefaultExcludeFiles() []string {
+	return append(DefaultLockfiles(), "codebase.md")
+}
+
+// DefaultGeneratedPatterns returns glob patterns for common generated source files.
+// They are prepended to ExcludePatterns when ExcludeGenerated is set.
+func DefaultGeneratedPatterns() []string {
+	return []string{
+		"*.pb.go", "*.pb.gw.go", "*_gen.go", "*.gen.go", "zz_generated*.go",
+		"*_mock.go", "mock_*.go", "*.min.js", "*.min.css", "*.generated.ts",
 	}
 }
 
-// Load populates a Config struct from environment variables and a .env file.
+// NewConfig returns a Config with every setting at its documented default.
+// OutputFile is left empty; the CLI names it after the repository at run time.
+func NewConfig() *Config {
+	return &Config{
+		MaxFileSize:    DefaultMaxFileSize,
+		SkipHiddenDirs: true,
+		SuccessMessage: DefaultSuccessMessage,
+		OutputEncoding: EncodingUTF8,
+		Formats:        []string{FormatMarkdown},
+		ChunkTokens:    DefaultChunkTokens,
+		PriorityFiles:  DefaultPriorityFiles(),
+		WarnOutputSize: DefaultWarnOutputSize,
+	}
+}
+
+// Load populates a Config struct from the selected profile in .code2md.yaml,
+// a .env file, and environment variables, in increasing order of precedence.
+// The profile is selected with CODE2MD_PROFILE and defaults to "default".
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
-	var c Config
+	// Start from the built-in defaults; later sources override them.
+	c := NewConfig()
+
+	if err := loadProfile(c, os.Getenv("CODE2MD_PROFILE")); err != nil {
+		return nil, err
+	}
 
-	err := envconfig.Process("CODE2MD", &c)
+	err := envconfig.Process(envPrefix, c)
 	if err != nil {
 		return nil, err
 	}
 
-	return &c, nil
+	return c, nil
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -56,3 +59,104 @@ func TestLoad(t *testing.T) {
 		t.Error("Expected Verbose to be true (from .env file), but got false")
 	}
 }
+
+func TestNewConfig(t *testing.T) {
+	cfg := NewConfig()
+
+	if cfg.MaxFileSize != DefaultMaxFileSize {
+		t.Errorf("Expected MaxFileSize %d, got %d", DefaultMaxFileSize, cfg.MaxFileSize)
+	}
+
+	if !slices.Equal(cfg.Formats, []string{FormatMarkdown}) {
+		t.Errorf("Expected Formats [%s], got %v", FormatMarkdown, cfg.Formats)
+	}
+
+	if cfg.ChunkTokens != DefaultChunkTokens || cfg.OutputEncoding != EncodingUTF8 || !cfg.SkipHiddenDirs {
+		t.Errorf("Expected documented defaults, got %+v", cfg)
+	}
+}
+
+func TestRegisterDefaultExtension(t *testing.T) {
+	const ext = ".zig"
+
+	RegisterDefaultExtension(ext)
+	RegisterDefaultExtension(ext)
+
+	t.Cleanup(func() { UnregisterDefaultExtension(ext) })
+
+	exts := DefaultExtensions()
+
+	count := 0
+
+	for _, e := range exts {
+		if e == ext {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("Expected %q to be registered exactly once, found %d occurrences", ext, count)
+	}
+
+	// Mutating the returned slice must not affect the registry.
+	exts[0] = "mutated"
+	if slices.Contains(DefaultExtensions(), "mutated") {
+		t.Error("Expected DefaultExtensions to return a copy")
+	}
+
+	UnregisterDefaultExtension(ext)
+
+	if slices.Contains(DefaultExtensions(), ext) {
+		t.Errorf("Expected %q to be removed after UnregisterDefaultExtension", ext)
+	}
+}
+
+func TestResolveProfile_Inheritance(t *testing.T) {
+	profiles := map[string]map[string]any{
+		"C": {"output_file": "c.md", "max_size": 100, "verbose": true},
+		"B": {"extends": "C", "max_size": 200, "exclude_dirs": []any{"dist"}},
+		"A": {"extends": "B", "output_file": "a.md"},
+	}
+
+	settings, err := resolveProfile(profiles, "A")
+	if err != nil {
+		t.Fatalf("resolveProfile() returned an unexpected error: %v", err)
+	}
+
+	var cfg Config
+	if err := applySettings(&cfg, settings); err != nil {
+		t.Fatalf("applySettings() returned an unexpected error: %v", err)
+	}
+
+	if cfg.OutputFile != "a.md" {
+		t.Errorf("Expected OutputFile from A, got %q", cfg.OutputFile)
+	}
+
+	if cfg.MaxFileSize != 200 {
+		t.Errorf("Expected MaxFileSize from B, got %d", cfg.MaxFileSize)
+	}
+
+	if !cfg.Verbose {
+		t.Error("Expected Verbose to be inherited from C")
+	}
+
+	if !slices.Equal(cfg.ExcludeDirs, []string{"dist"}) {
+		t.Errorf("Expected ExcludeDirs from B, got %v", cfg.ExcludeDirs)
+	}
+}
+
+func TestResolveProfile_Cycle(t *testing.T) {
+	profiles := map[string]map[string]any{
+		"A": {"extends": "B"},
+		"B": {"extends": "A"},
+	}
+
+	_, err := resolveProfile(profiles, "A")
+	if !errors.Is(err, ErrProfileCycle) {
+		t.Fatalf("Expected ErrProfileCycle, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "A -> B -> A") {
+		t.Errorf("Expected error to contain the cycle path, got %q", err.Error())
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the optional configuration file read from the working directory.
+const FileName = ".code2md.yaml"
+
+// DefaultProfileName is the profile applied when CODE2MD_PROFILE is not set.
+const DefaultProfileName = "default"
+
+// extendsKey is the profile key naming the parent profile to inherit from.
+const extendsKey = "extends"
+
+var (
+	// ErrUnknownProfile is returned when a requested or extended profile is not defined.
+	ErrUnknownProfile = errors.New("unknown profile")
+	// ErrProfileCycle is returned when profile inheritance forms a cycle.
+	ErrProfileCycle = errors.New("profile inheritance cycle")
+)
+
+// configFile is the on-disk layout of FileName.
+type configFile struct {
+	Profiles map[string]map[string]any `yaml:"profiles"`
+}
+
+// loadProfile applies the named profile from FileName in the working directory to cfg.
+// A missing config file is not an error; a missing profile is an error unless it is the
+// default profile.
+func loadProfile(cfg *Config, name string) error {
+	settings, err := profileSettings(name)
+	if err != nil || settings == nil {
+		return err
+	}
+
+	return applySettings(cfg, settings)
+}
+
+// profileSettings reads FileName from the working directory and returns the merged
+// settings of the named profile, or nil when there is nothing to apply.
+func profileSettings(name string) (map[string]any, error) {
+	data, err := os.ReadFile(FileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+
+	if name == "" {
+		name = DefaultProfileName
+		if _, ok := file.Profiles[name]; !ok {
+			return nil, nil
+		}
+	}
+
+	return resolveProfile(file.Profiles, name)
+}
+
+// resolveProfile walks the extends chain of the named profile and merges the settings
+// from the root ancestor down to the named profile, so children override parents.
+func resolveProfile(profiles map[string]map[string]any, name string) (map[string]any, error) {
+	var chain []string
+
+	seen := make(map[string]bool)
+
+	for current := name; current != ""; {
+		if seen[current] {
+			return nil, fmt.Errorf("%w: %s", ErrProfileCycle, strings.Join(append(chain, current), " -> "))
+		}
+
+		profile, ok := profiles[current]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, current)
+		}
+
+		seen[current] = true
+		chain = append(chain, current)
+
+		parent, _ := profile[extendsKey].(string)
+		current = parent
+	}
+
+	merged := make(map[string]any)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		maps.Copy(merged, profiles[chain[i]])
+	}
+
+	delete(merged, extendsKey)
+
+	return merged, nil
+}
+
+// applySettings decodes the merged profile settings into cfg using its yaml tags.
+func applySettings(cfg *Config, settings map[string]any) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("invalid profile settings: %w", err)
+	}
+
+	return nil
+}
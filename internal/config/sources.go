@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// envPrefix is the prefix of every environment variable read by Load.
+const envPrefix = "CODE2MD"
+
+// Value sources reported by Sources.
+const (
+	SourceDefault = "default"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Sources reports where Load took each setting from, keyed by its yaml name.
+// Values loaded from a .env file count as SourceEnv. Flags are not known here,
+// so callers overlay SourceFlag for the flags that were set explicitly.
+func Sources() (map[string]string, error) {
+	settings, err := profileSettings(os.Getenv("CODE2MD_PROFILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string)
+	t := reflect.TypeFor[Config]()
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key := yamlKey(field)
+
+		switch _, inProfile := settings[key]; {
+		case envSet(field.Tag.Get("envconfig")):
+			sources[key] = SourceEnv
+		case inProfile:
+			sources[key] = SourceProfile
+		default:
+			sources[key] = SourceDefault
+		}
+	}
+
+	return sources, nil
+}
+
+// CopySetting copies the setting with the given yaml key from src to c. It reports
+// whether Config has such a setting.
+func (c *Config) CopySetting(src *Config, key string) bool {
+	dst, from := reflect.ValueOf(c).Elem(), reflect.ValueOf(src).Elem()
+
+	for i := range dst.NumField() {
+		if yamlKey(dst.Type().Field(i)) == key {
+			dst.Field(i).Set(from.Field(i))
+			return true
+		}
+	}
+
+	return false
+}
+
+// yamlKey returns the yaml name of a Config field.
+func yamlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// envSet reports whether the environment variable backing an envconfig tag is set.
+func envSet(tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	_, ok := os.LookupEnv(envPrefix + "_" + tag)
+
+	return ok
+}
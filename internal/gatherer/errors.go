@@ -0,0 +1,43 @@
+package gatherer
+
+import (
+	"errors"
+	"io/fs"
+
+	"go.uber.org/zap"
+)
+
+// GatherError records the file and operation behind a gathering failure, so that the
+// file can be identified without verbose logging.
+type GatherError struct {
+	Path string // File or directory involved, relative to the root directory when inside it.
+	Op   string // Operation that failed, such as "read" or "stat".
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *GatherError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *GatherError) Unwrap() error {
+	return e.Err
+}
+
+// gatherError wraps err with the operation and path, relative to the root directory when possible.
+func (fg *FileGatherer) gatherError(op, path string, err error) *GatherError {
+	return &GatherError{Path: fg.gitignoreParser.displayPath(path), Op: op, Err: err}
+}
+
+// warnAccess logs a failure to access path. A path that no longer exists was removed
+// after the walk listed it, which is common on an active machine, so it is skipped
+// with a debug message instead of a warning.
+func (fg *FileGatherer) warnAccess(msg, op, path string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		fg.logger.Debug("Skipping path (removed during walk)", zap.String("path", path))
+		return
+	}
+
+	fg.logger.Warn(msg, zap.Error(fg.gatherError(op, path, err)))
+}
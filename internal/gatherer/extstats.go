@@ -0,0 +1,40 @@
+package gatherer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ExtensionStats tallies what happened to the files with one extension during a run.
+type ExtensionStats struct {
+	Included   int
+	TooLarge   int // Over MaxFileSize.
+	Binary     int
+	Gitignored int // Ignored individually; files inside ignored directories are never seen.
+}
+
+// extensionKey returns the lower-cased extension of path, or its file name when it has none,
+// matching how extension filters treat names such as Makefile.
+func extensionKey(path string) string {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != "" {
+		return ext
+	}
+
+	return filepath.Base(path)
+}
+
+// countExtension applies count to the statistics of the extension of path.
+// It is safe for concurrent use by the workers.
+func (fg *FileGatherer) countExtension(path string, count func(*ExtensionStats)) {
+	fg.extStatsMu.Lock()
+	defer fg.extStatsMu.Unlock()
+
+	if fg.stats.Extensions == nil {
+		fg.stats.Extensions = make(map[string]ExtensionStats)
+	}
+
+	key := extensionKey(path)
+	stats := fg.stats.Extensions[key]
+	count(&stats)
+	fg.stats.Extensions[key] = stats
+}
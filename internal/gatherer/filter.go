@@ -0,0 +1,29 @@
+package gatherer
+
+import "io/fs"
+
+// FilterFunc decides whether a file that passed the built-in filters is gathered.
+// It receives the absolute path, the file's info, and its content. When it excludes
+// the file, reason explains why and is logged at debug level.
+type FilterFunc func(path string, info fs.FileInfo, content []byte) (include bool, reason string)
+
+// GathererOption customizes a FileGatherer created by NewFileGatherer.
+type GathererOption func(*FileGatherer)
+
+// WithFilter registers a custom filter, applied after the built-in checks in registration order.
+func WithFilter(fn FilterFunc) GathererOption {
+	return func(fg *FileGatherer) {
+		fg.filters = append(fg.filters, fn)
+	}
+}
+
+// applyFilters runs the custom filters on a file, reporting whether all of them include it.
+func (fg *FileGatherer) applyFilters(path string, info fs.FileInfo, content []byte) (bool, string) {
+	for _, filter := range fg.filters {
+		if include, reason := filter(path, info, content); !include {
+			return false, reason
+		}
+	}
+
+	return true, ""
+}
@@ -0,0 +1,102 @@
+package gatherer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// withFS makes the gatherer read the tree from fsys, as if its root were checked out
+// at the gatherer's root path, instead of from the operating system.
+func withFS(fsys fs.FS) GathererOption {
+	return func(fg *FileGatherer) {
+		fg.tree.fsys = fsys
+	}
+}
+
+// rootedFS resolves the absolute paths used by the gatherer against an optional fs.FS
+// rooted at root. A nil fsys means the operating system's file system.
+type rootedFS struct {
+	fsys fs.FS
+	root string
+}
+
+// name returns the fs.FS name of path, or false when path is outside the root.
+func (r rootedFS) name(path string) (string, bool) {
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
+
+// open opens the file at path.
+func (r rootedFS) open(path string) (fs.File, error) {
+	if r.fsys == nil {
+		return os.Open(path)
+	}
+
+	name, ok := r.name(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return r.fsys.Open(name)
+}
+
+// readFile returns the content of the file at path.
+func (r rootedFS) readFile(path string) ([]byte, error) {
+	if r.fsys == nil {
+		return os.ReadFile(path)
+	}
+
+	name, ok := r.name(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return fs.ReadFile(r.fsys, name)
+}
+
+// stat returns the file info of path.
+func (r rootedFS) stat(path string) (fs.FileInfo, error) {
+	if r.fsys == nil {
+		return os.Stat(path)
+	}
+
+	name, ok := r.name(path)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return fs.Stat(r.fsys, name)
+}
+
+// walkDir walks the tree at the root, passing absolute paths to fn like filepath.WalkDir.
+func (r rootedFS) walkDir(fn fs.WalkDirFunc) error {
+	if r.fsys == nil {
+		return filepath.WalkDir(r.root, fn)
+	}
+
+	return fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		// Like filepath.WalkDir, name the root after its path rather than ".".
+		if name == "." && d != nil {
+			d = rootDirEntry{DirEntry: d, name: filepath.Base(r.root)}
+		}
+
+		return fn(filepath.Join(r.root, filepath.FromSlash(name)), d, err)
+	})
+}
+
+// rootDirEntry is the directory entry of the walked root under its own name.
+type rootDirEntry struct {
+	fs.DirEntry
+	name string
+}
+
+// Name returns the base name of the root path.
+func (e rootDirEntry) Name() string {
+	return e.name
+}
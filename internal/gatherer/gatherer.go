@@ -1,24 +1,79 @@
 package gatherer
 
 import (
+	"bytes"
 	"code2md/internal/config"
 	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/time/rate"
 )
 
+// ErrLargeFiles is returned in FailOnLargeFile mode when files exceed MaxFileSize.
+var ErrLargeFiles = errors.New("files exceed the maximum file size")
+
+// errFileLimitReached stops the walk once MaxFiles paths have been handed to the workers.
+var errFileLimitReached = errors.New("file limit reached")
+
 // FileInfo holds the details of a gathered file.
 type FileInfo struct {
-	Path    string
-	Size    int64
+	Path string
+	Size int64
+	// Content holds the file as a string. It takes precedence over RawContent when set.
+	//
+	// Deprecated: set RawContent and read the content through Text to avoid copying the file.
 	Content string
+	// RawContent holds the file as read from disk; it is only decoded when written.
+	RawContent []byte
+	Language   string
+	ModTime    time.Time
+	// RealPath is the root-relative path a symlinked file resolves to, set with RelativizeSymlinks.
+	RealPath string
+	// SameAs lists the other gathered paths that resolve to the same underlying file.
+	SameAs []string
+}
+
+// Text returns the file content, preferring Content when both it and RawContent are set.
+func (f FileInfo) Text() string {
+	if f.Content != "" {
+		return f.Content
+	}
+
+	return string(f.RawContent)
+}
+
+// GatherStats holds aggregate counters collected during a gathering run.
+type GatherStats struct {
+	SkippedDirs  int
+	SkippedFiles int                       // Files seen during the walk but not gathered, for any reason.
+	Files        []FileInfo                // Set by DryRun: the files that would be gathered, without content.
+	Extensions   map[string]ExtensionStats // Per-extension tally of included and skipped files.
+}
+
+// fileFilters bundles the prepared include/exclude rules applied to each file.
+type fileFilters struct {
+	extInclude      map[string]bool
+	extExclude      map[string]bool
+	excludePatterns GlobSet
+	includeOnly     GlobSet          // When non-nil, only files matching these patterns are gathered.
+	includeFile     *GitignoreParser // When non-nil, only files matching the .code2mdinclude patterns are gathered.
+	changedOnly     map[string]bool  // When non-nil, only these relative paths are gathered.
+	languages       map[string]bool  // When non-empty, only files of these languages are gathered.
 }
 
 // FileGatherer is responsible for collecting files from the filesystem.
@@ -28,50 +83,204 @@ type FileGatherer struct {
 	logger          *zap.Logger
 	gitignoreParser *GitignoreParser
 	gitignoreExists bool // Flag to track if .gitignore was found.
+	stats           GatherStats
+	readLimiter     *rate.Limiter // Shared across workers; nil when reads are unthrottled.
+	outputPath      string        // Absolute path of the output file, which is never gathered.
+	outputPatterns  []string      // Absolute patterns of other outputs, which are never gathered either.
+	relativeTo      string        // Absolute directory that output paths are relative to; rootPath when empty.
+	seenFiles       int           // Files visited by the producer in the current run.
+	sentFiles       atomic.Int64  // Paths handed to the workers in the current run, counted against MaxFiles.
+	memGate         *memoryGate   // Shared across workers; nil when memory is uncapped.
+	largeFilesMu    sync.Mutex
+	largeFiles      []string // Relative paths over MaxFileSize, collected in FailOnLargeFile mode.
+	filters         []FilterFunc
+	tree            rootedFS // The file system holding rootPath.
+	extStatsMu      sync.Mutex
 }
 
 // NewFileGatherer creates a new FileGatherer.
-func NewFileGatherer(cfg *config.Config, rootPath string, logger *zap.Logger) *FileGatherer {
-	gitignoreParser := NewGitignoreParser(rootPath)
-	err := gitignoreParser.LoadGitignore()
+func NewFileGatherer(cfg *config.Config, rootPath string, logger *zap.Logger, opts ...GathererOption) *FileGatherer {
+	fg := &FileGatherer{
+		config:   cfg,
+		rootPath: rootPath,
+		tree:     rootedFS{root: rootPath},
+		logger:   logger,
+	}
 
-	// Check if the error was specifically "file does not exist".
-	gitignoreExists := !os.IsNotExist(err)
-	if err != nil && gitignoreExists {
+	for _, opt := range opts {
+		opt(fg)
+	}
+
+	gitignoreParser := NewGitignoreParser(rootPath)
+	gitignoreParser.tree = fg.tree
+	// The minimal directory exclusions apply only when an ignore file was loaded.
+	gitignoreExists, err := gitignoreParser.LoadGitignore()
+	if err != nil {
 		logger.Warn("Failed to load or parse .gitignore", zap.Error(err))
+
+		gitignoreExists = false
+	}
+
+	parentFound, err := gitignoreParser.LoadParentGitignores()
+	if err != nil {
+		logger.Warn("Failed to load or parse a parent .gitignore", zap.Error(err))
+	}
+
+	gitignoreExists = gitignoreExists || parentFound
+
+	if gitignoreParser.isHgRepo() {
+		hgFound, hgErr := gitignoreParser.LoadHgignore()
+		if hgErr != nil {
+			logger.Warn("Failed to load or parse .hgignore", zap.Error(hgErr))
+		}
+
+		gitignoreExists = gitignoreExists || (hgFound && hgErr == nil)
+	}
+
+	if !cfg.NoGitInfoExclude {
+		if excludeErr := gitignoreParser.LoadGitInfoExclude(); excludeErr != nil {
+			logger.Warn("Failed to load or parse .git/info/exclude", zap.Error(excludeErr))
+		}
+	}
+
+	if cfg.NpmIgnore {
+		if npmErr := gitignoreParser.LoadIgnoreFile(".npmignore"); npmErr != nil {
+			logger.Warn("Failed to load or parse .npmignore", zap.Error(npmErr))
+		}
+	}
+
+	for _, ignoreFile := range cfg.IgnoreFiles {
+		if ignoreErr := gitignoreParser.LoadIgnoreFileAt(ignoreFile); ignoreErr != nil {
+			logger.Warn("Failed to load or parse ignore file", zap.String("path", ignoreFile), zap.Error(ignoreErr))
+		}
 	}
 
-	return &FileGatherer{
-		config:          cfg,
-		rootPath:        rootPath,
-		logger:          logger,
-		gitignoreParser: gitignoreParser,
-		gitignoreExists: gitignoreExists,
+	if cfg.OutputFile != "" {
+		fg.outputPath, _ = filepath.Abs(cfg.OutputFile)
 	}
+
+	if cfg.RelativeTo != "" {
+		if fg.relativeTo, err = filepath.Abs(cfg.RelativeTo); err != nil {
+			logger.Warn("Cannot resolve --relative-to; paths stay relative to the root", zap.Error(err))
+		}
+	}
+
+	fg.gitignoreParser = gitignoreParser
+	fg.gitignoreExists = gitignoreExists
+
+	return fg
+}
+
+// IgnoreSources returns the ignore files applied by the gatherer and the patterns loaded from each.
+func (fg *FileGatherer) IgnoreSources() []IgnoreSource {
+	return fg.gitignoreParser.Sources()
+}
+
+// Stats returns the counters collected by the most recent GatherFiles or DryRun run.
+func (fg *FileGatherer) Stats() GatherStats {
+	return fg.stats
 }
 
 // GatherFiles orchestrates the concurrent file gathering pipeline.
 func (fg *FileGatherer) GatherFiles(ctx context.Context) ([]FileInfo, error) {
-	extInclude, extExclude := fg.prepareExtensionFilters()
+	fg.resetRun()
+
+	if limit := fg.config.MaxReadBytesPerSec; limit > 0 {
+		fg.readLimiter = rate.NewLimiter(rate.Limit(limit), int(limit))
+	}
+
+	if limit := fg.config.MaxMemory; limit > 0 {
+		fg.memGate = &memoryGate{limit: uint64(limit)}
+
+		// Let the garbage collector work harder as the heap nears the cap while gathering.
+		// The runtime limit is process-wide, so it is only ever lowered, and restored after.
+		if limit < debug.SetMemoryLimit(-1) {
+			defer debug.SetMemoryLimit(debug.SetMemoryLimit(limit))
+		}
+	}
+
+	files, err := fg.gather(ctx, fg.processFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fg.warnUnmatchedIncludes(files)
+
+	return files, nil
+}
+
+// DryRun walks the tree and applies every filter like GatherFiles, but only stats the
+// matching files instead of reading them. The files are returned in GatherStats.Files
+// with their size and modification time. Since content is not read, binary files that
+// GatherFiles would skip are included.
+func (fg *FileGatherer) DryRun(ctx context.Context) (GatherStats, error) {
+	fg.resetRun()
+
+	files, err := fg.gather(ctx, func(_ context.Context, path string, filters *fileFilters) (FileInfo, bool) {
+		return fg.matchFile(path, filters)
+	})
+	if err != nil {
+		return GatherStats{}, err
+	}
+
+	fg.stats.Files = files
+
+	return fg.stats, nil
+}
+
+// processFunc turns a walked path into a gathered file, reporting whether it is kept.
+type processFunc func(ctx context.Context, path string, filters *fileFilters) (FileInfo, bool)
+
+// resetRun clears the state left by a previous run.
+func (fg *FileGatherer) resetRun() {
+	fg.stats = GatherStats{}
+	fg.seenFiles = 0
+	fg.sentFiles.Store(0)
+	fg.readLimiter = nil
+	fg.memGate = nil
+	fg.largeFiles = nil
+}
+
+// gather runs the concurrent walk with process applied to every candidate path,
+// and returns the kept files sorted by path.
+func (fg *FileGatherer) gather(ctx context.Context, process processFunc) ([]FileInfo, error) {
+	filters, err := fg.prepareFileFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Pass the gitignore existence flag to the directory filter preparation.
-	dirExclude := fg.prepareDirFilters(fg.gitignoreExists)
+	dirExclude, err := fg.prepareDirFilters(fg.gitignoreExists)
+	if err != nil {
+		return nil, err
+	}
 
 	paths := make(chan string)
 	results := make(chan FileInfo)
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
-		return fg.producer(ctx, paths, dirExclude)
+		err := fg.producer(ctx, paths, dirExclude, filters.extInclude)
+		if errors.Is(err, errFileLimitReached) {
+			// The walk stopped on purpose; returning nil lets the workers finish the paths already sent.
+			fg.logger.Info("Stopped walking at the file limit", zap.Int("max_files", fg.config.MaxFiles))
+			return nil
+		}
+
+		return err
 	})
 
 	for i := 0; i < runtime.NumCPU(); i++ {
 		g.Go(func() error {
-			return fg.worker(ctx, paths, results, extInclude, extExclude)
+			return fg.worker(ctx, paths, results, filters, process)
 		})
 	}
 
+	// Capture the pipeline's first error once, then close results so the loop below ends.
+	waitErr := make(chan error, 1)
+
 	go func() {
-		_ = g.Wait()
+		waitErr <- g.Wait()
 
 		close(results)
 	}()
@@ -81,60 +290,174 @@ func (fg *FileGatherer) GatherFiles(ctx context.Context) ([]FileInfo, error) {
 		files = append(files, file)
 	}
 
-	if err := g.Wait(); err != nil {
+	if err := <-waitErr; err != nil {
 		return nil, err
 	}
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Path < files[j].Path
-	})
+	if len(fg.largeFiles) > 0 {
+		sort.Strings(fg.largeFiles)
+
+		return nil, fmt.Errorf("%w (%d bytes): %s",
+			ErrLargeFiles, fg.config.MaxFileSize, strings.Join(fg.largeFiles, ", "))
+	}
+
+	for _, file := range files {
+		fg.countExtension(file.Path, func(s *ExtensionStats) { s.Included++ })
+	}
+
+	fg.sortFiles(files)
+
+	if fg.config.RelativizeSymlinks {
+		crossReferenceSymlinks(files)
+	}
+
+	if fg.relativeTo != "" {
+		fg.rebasePaths(files)
+	}
+
+	if fg.config.StripPrefix != "" {
+		fg.stripPathPrefix(files)
+	}
+
+	fg.stats.SkippedFiles = fg.seenFiles - len(files)
 
 	return files, nil
 }
 
+// rebasePaths makes the root-relative paths of the files relative to the --relative-to
+// directory instead. Files outside that directory keep a path starting with "..", with a warning.
+func (fg *FileGatherer) rebasePaths(files []FileInfo) {
+	rebase := func(relPath string) string {
+		rebased, err := filepath.Rel(fg.relativeTo, filepath.Join(fg.rootPath, relPath))
+		if err != nil {
+			return relPath
+		}
+
+		return rebased
+	}
+
+	for i := range files {
+		files[i].Path = rebase(files[i].Path)
+		if files[i].Path == ".." || strings.HasPrefix(files[i].Path, ".."+string(filepath.Separator)) {
+			fg.logger.Warn("File is outside the --relative-to directory",
+				zap.String("path", files[i].Path), zap.String("relative_to", fg.relativeTo))
+		}
+
+		for j, other := range files[i].SameAs {
+			files[i].SameAs[j] = rebase(other)
+		}
+	}
+}
+
+// warnUnmatchedIncludes logs a warning for each user-supplied include extension or
+// language that none of the gathered files matched, since such a filter is likely a typo
+// or targets files that are not in the tree.
+func (fg *FileGatherer) warnUnmatchedIncludes(files []FileInfo) {
+	matchedExt := make(map[string]bool)
+	matchedLang := make(map[string]bool)
+
+	for _, file := range files {
+		matchedExt[filepath.Base(file.Path)] = true
+		matchedExt[filepath.Ext(file.Path)] = true
+		matchedLang[file.Language] = true
+	}
+
+	for _, ext := range fg.config.IncludeExt {
+		if !matchedExt[ext] {
+			fg.logger.Warn("Include filter matched 0 files", zap.String("include", ext))
+		}
+	}
+
+	for _, lang := range fg.config.Languages {
+		if !matchedLang[strings.ToLower(lang)] {
+			fg.logger.Warn("Language filter matched 0 files", zap.String("language", lang))
+		}
+	}
+}
+
 // producer walks the filesystem and sends candidate file paths to the paths channel.
-func (fg *FileGatherer) producer(ctx context.Context, paths chan<- string, dirExclude map[string]bool) error {
+func (fg *FileGatherer) producer(ctx context.Context, paths chan<- string, dirExclude *dirFilter, extInclude map[string]bool) error {
 	defer close(paths)
 
-	return filepath.WalkDir(fg.rootPath, func(path string, d fs.DirEntry, err error) error {
+	hiddenDirs := make(map[string]bool)
+	for _, dir := range config.DefaultHiddenDirs() {
+		hiddenDirs[dir] = true
+	}
+
+	return fg.tree.walkDir(func(path string, d fs.DirEntry, err error) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 			if err != nil {
-				fg.logger.Warn("Cannot access path", zap.String("path", path), zap.Error(err))
+				// Without the root there is nothing to gather, so fail instead of returning no files.
+				if path == fg.rootPath {
+					return &GatherError{Path: path, Op: "walk", Err: err}
+				}
+
+				fg.warnAccess("Cannot access path", "access", path, err)
+
 				return nil
 			}
 
+			if !d.IsDir() {
+				fg.seenFiles++
+			}
+
 			// Always check gitignore first. This is the highest priority.
-			if fg.gitignoreParser.ShouldIgnore(path) {
+			if fg.gitignored(path, d.IsDir()) {
 				if d.IsDir() {
 					fg.logger.Debug("Skipping directory tree (gitignore)", zap.String("dir", path))
+					fg.stats.SkippedDirs++
+
 					return filepath.SkipDir
 				}
 
 				fg.logger.Debug("Skipping file (gitignore)", zap.String("file", path))
+				fg.countExtension(path, func(s *ExtensionStats) { s.Gitignored++ })
 
 				return nil
 			}
 
 			// Handle default directory and hidden directory exclusions.
 			if d.IsDir() {
-				if dirExclude[d.Name()] || fg.shouldSkipHidden(d.Name()) {
+				if path != fg.rootPath && fg.isOutput(path) {
+					fg.logger.Debug("Skipping directory tree (output)", zap.String("dir", path))
+					fg.stats.SkippedDirs++
+
+					return filepath.SkipDir
+				}
+
+				if dirExclude.excludes(d.Name()) || (fg.shouldSkipHiddenDir(d.Name()) && !hiddenDirs[d.Name()]) {
 					fg.logger.Debug("Skipping directory tree", zap.String("dir", d.Name()))
+					fg.stats.SkippedDirs++
+
 					return filepath.SkipDir
 				}
 
 				return nil
 			}
 
-			if fg.shouldSkipHidden(d.Name()) {
+			// Hidden files explicitly listed by name (e.g., .env.example) are still gathered.
+			if fg.shouldSkipHidden(d.Name()) && !extInclude[d.Name()] {
 				return nil
 			}
 
-			paths <- path
+			// Stop at the first path beyond the limit, so that the walk only counts as cut
+			// short when a file was left out. The deferred close tells the workers that no
+			// more paths are coming.
+			if limit := fg.config.MaxFiles; limit > 0 && fg.sentFiles.Load() >= int64(limit) {
+				return errFileLimitReached
+			}
 
-			return nil
+			// Workers stop on cancellation, so a blocking send could otherwise hang the walk.
+			select {
+			case paths <- path:
+				fg.sentFiles.Add(1)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 	})
 }
@@ -144,14 +467,15 @@ func (fg *FileGatherer) worker(
 	ctx context.Context,
 	paths <-chan string,
 	results chan<- FileInfo,
-	extInclude, extExclude map[string]bool,
+	filters *fileFilters,
+	process processFunc,
 ) error {
 	for path := range paths {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			fileInfo, shouldAdd := fg.processFile(path, extInclude, extExclude)
+			fileInfo, shouldAdd := process(ctx, path, filters)
 			if shouldAdd {
 				results <- fileInfo
 			}
@@ -162,35 +486,88 @@ func (fg *FileGatherer) worker(
 }
 
 // processFile performs the "heavy" work on a single file path.
-func (fg *FileGatherer) processFile(path string, extInclude, extExclude map[string]bool) (FileInfo, bool) {
-	if !fg.shouldIncludeFile(path, extInclude, extExclude) {
+func (fg *FileGatherer) processFile(ctx context.Context, path string, filters *fileFilters) (FileInfo, bool) {
+	file, ok := fg.matchFile(path, filters)
+	if !ok {
 		return FileInfo{}, false
 	}
 
-	info, err := os.Stat(path)
-	if err != nil {
-		fg.logger.Warn("Cannot get info for file", zap.String("path", path), zap.Error(err))
+	if err := fg.waitForRead(ctx, file.Size); err != nil {
 		return FileInfo{}, false
 	}
 
-	if info.Size() > fg.config.MaxFileSize {
-		fg.logger.Debug("Skipping large file",
-			zap.String("path", path),
-			zap.Int64("size", info.Size()),
-			zap.Int64("max_size", fg.config.MaxFileSize),
-		)
+	if fg.memGate != nil {
+		if err := fg.memGate.acquire(ctx); err != nil {
+			return FileInfo{}, false
+		}
 
-		return FileInfo{}, false
+		defer fg.memGate.release()
 	}
 
-	content, err := os.ReadFile(path)
+	content, err := fg.tree.readFile(path)
 	if err != nil {
-		fg.logger.Warn("Cannot read file", zap.String("path", path), zap.Error(err))
+		fg.warnAccess("Cannot read file", "read", path, err)
 		return FileInfo{}, false
 	}
 
 	if isBinary(content) {
 		fg.logger.Debug("Skipping binary file", zap.String("path", path))
+		fg.countExtension(path, func(s *ExtensionStats) { s.Binary++ })
+
+		return FileInfo{}, false
+	}
+
+	// Empty files are kept; only files with whitespace and nothing else are skipped.
+	if fg.config.SkipWhitespaceOnly && len(content) > 0 && len(bytes.TrimSpace(content)) == 0 {
+		fg.logger.Debug("Skipping whitespace-only file", zap.String("path", path))
+		return FileInfo{}, false
+	}
+
+	if limit := fg.config.MaxFileTokens; limit > 0 {
+		if tokens := EstimateContentTokens(content); tokens > limit {
+			fg.logger.Debug("Skipping file (too many tokens)",
+				zap.String("path", path),
+				zap.Int("estimated_tokens", tokens),
+				zap.Int("max_tokens", limit),
+			)
+
+			return FileInfo{}, false
+		}
+	}
+
+	if len(fg.filters) > 0 {
+		info, err := fg.tree.stat(path)
+		if err != nil {
+			fg.warnAccess("Cannot get info for file", "stat", path, err)
+			return FileInfo{}, false
+		}
+
+		if include, reason := fg.applyFilters(path, info, content); !include {
+			fg.logger.Debug("Skipping file (custom filter)", zap.String("path", file.Path), zap.String("reason", reason))
+			return FileInfo{}, false
+		}
+	}
+
+	fg.logger.Debug("Added file", zap.String("path", file.Path))
+
+	file.RawContent = content
+
+	if fg.config.RelativizeSymlinks {
+		file.RealPath = fg.resolveSymlink(path)
+	}
+
+	return file, true
+}
+
+// matchFile applies the path, language, and size filters to a file without reading it.
+// It returns the file's metadata when the file passes.
+func (fg *FileGatherer) matchFile(path string, filters *fileFilters) (FileInfo, bool) {
+	if fg.isOutput(path) {
+		fg.logger.Debug("Skipping file (output file)", zap.String("path", path))
+		return FileInfo{}, false
+	}
+
+	if !fg.shouldIncludeFile(path, filters.extInclude, filters.extExclude) {
 		return FileInfo{}, false
 	}
 
@@ -199,20 +576,347 @@ func (fg *FileGatherer) processFile(path string, extInclude, extExclude map[stri
 		relPath = path // Fallback to absolute path if Rel fails
 	}
 
-	fg.logger.Debug("Added file", zap.String("path", relPath))
+	// macOS file systems may report decomposed (NFD) names; use NFC so paths match across platforms.
+	relPath = norm.NFC.String(relPath)
+
+	if filters.excludePatterns.Match(relPath) {
+		fg.logger.Debug("Skipping file (exclude pattern)", zap.String("path", relPath))
+		return FileInfo{}, false
+	}
+
+	if filters.includeOnly != nil && !filters.includeOnly.MatchPath(relPath) {
+		fg.logger.Debug("Skipping file (not in include list)", zap.String("path", relPath))
+		return FileInfo{}, false
+	}
+
+	if filters.includeFile != nil && !filters.includeFile.Matches(path) {
+		fg.logger.Debug("Skipping file (not in "+IncludeFileName+")", zap.String("path", relPath))
+		return FileInfo{}, false
+	}
+
+	if filters.changedOnly != nil && !filters.changedOnly[relPath] {
+		fg.logger.Debug("Skipping file (unchanged since tag)", zap.String("path", relPath))
+		return FileInfo{}, false
+	}
+
+	language := LanguageFromPath(relPath)
+	if len(filters.languages) > 0 && !filters.languages[language] {
+		fg.logger.Debug("Skipping file (language)", zap.String("path", relPath), zap.String("language", language))
+		return FileInfo{}, false
+	}
+
+	if fg.config.ExcludeTests || fg.config.TestsOnly {
+		if IsTestFile(relPath, language) != fg.config.TestsOnly {
+			fg.logger.Debug("Skipping file (test filter)", zap.String("path", relPath))
+			return FileInfo{}, false
+		}
+	}
+
+	info, err := fg.tree.stat(path)
+	if err != nil {
+		fg.warnAccess("Cannot get info for file", "stat", path, err)
+		return FileInfo{}, false
+	}
+
+	maxSize := fg.config.MaxFileSize
+	if fg.isPriorityFile(path) {
+		maxSize = max(maxSize, config.MaxPriorityFileSize)
+	}
+
+	if info.Size() > maxSize {
+		if fg.config.FailOnLargeFile {
+			fg.largeFilesMu.Lock()
+			fg.largeFiles = append(fg.largeFiles, relPath)
+			fg.largeFilesMu.Unlock()
+		}
+
+		fg.countExtension(path, func(s *ExtensionStats) { s.TooLarge++ })
+		fg.logger.Debug("Skipping large file",
+			zap.String("path", path),
+			zap.Int64("size", info.Size()),
+			zap.Int64("max_size", maxSize),
+		)
+
+		return FileInfo{}, false
+	}
 
 	return FileInfo{
-		Path:    relPath,
-		Size:    info.Size(),
-		Content: string(content),
+		Path:     relPath,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Language: language,
 	}, true
 }
 
+// sortFiles orders the gathered files by path, or by language and then path with
+// SortLanguage. Dependency manifests give context for everything else, so they come first.
+func (fg *FileGatherer) sortFiles(files []FileInfo) {
+	byLanguage := fg.config.Sort == config.SortLanguage
+
+	sort.Slice(files, func(i, j int) bool {
+		if pi, pj := fg.isPriorityFile(files[i].Path), fg.isPriorityFile(files[j].Path); pi != pj {
+			return pi
+		}
+
+		if byLanguage && files[i].Language != files[j].Language {
+			return files[i].Language < files[j].Language
+		}
+
+		return files[i].Path < files[j].Path
+	})
+}
+
+// isPriorityFile reports whether the file at path is one of the configured PriorityFiles.
+func (fg *FileGatherer) isPriorityFile(path string) bool {
+	return slices.Contains(fg.config.PriorityFiles, filepath.Base(path))
+}
+
+// resolveSymlink returns the root-relative path that the symlink at path resolves to,
+// or "" when path is not a symlink or resolves outside the root directory.
+func (fg *FileGatherer) resolveSymlink(path string) string {
+	if fg.tree.fsys != nil {
+		return "" // Symlinks are only followed on the operating system's file system.
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return ""
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		fg.logger.Debug("Cannot resolve symlink", zap.String("path", path), zap.Error(err))
+		return ""
+	}
+
+	// The root itself may live under a symlinked directory, such as a temporary directory.
+	root, err := filepath.EvalSymlinks(fg.rootPath)
+	if err != nil {
+		return ""
+	}
+
+	relTarget, err := filepath.Rel(root, target)
+	if err != nil || relTarget == ".." || strings.HasPrefix(relTarget, ".."+string(filepath.Separator)) {
+		return ""
+	}
+
+	return norm.NFC.String(filepath.ToSlash(relTarget))
+}
+
+// crossReferenceSymlinks fills SameAs for gathered files that resolve to the same
+// underlying file, whether they are symlinks to it or the file itself.
+func crossReferenceSymlinks(files []FileInfo) {
+	groups := make(map[string][]int)
+
+	for i, file := range files {
+		key := file.Path
+		if file.RealPath != "" {
+			key = file.RealPath
+		}
+
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		for _, i := range group {
+			for _, j := range group {
+				if i != j {
+					files[i].SameAs = append(files[i].SameAs, files[j].Path)
+				}
+			}
+		}
+	}
+}
+
+// prepareFileFilters builds the extension maps and compiles the exclude patterns.
+func (fg *FileGatherer) prepareFileFilters(ctx context.Context) (*fileFilters, error) {
+	extInclude, extExclude := fg.prepareExtensionFilters()
+
+	patterns := fg.config.ExcludePatterns
+	if fg.config.ExcludeGenerated {
+		patterns = append(config.DefaultGeneratedPatterns(), patterns...)
+	}
+
+	excludePatterns, err := CompileGlobSet(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := &fileFilters{
+		extInclude:      extInclude,
+		extExclude:      extExclude,
+		excludePatterns: excludePatterns,
+		languages:       make(map[string]bool),
+	}
+
+	for _, lang := range fg.config.Languages {
+		filters.languages[strings.ToLower(lang)] = true
+	}
+
+	if fg.config.NpmOnly {
+		filters.includeOnly, err = loadNpmFilesWhitelist(fg.tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	includeParser := NewGitignoreParser(fg.rootPath)
+	includeParser.tree = fg.tree
+
+	found, err := includeParser.LoadIncludeFile()
+	if err != nil {
+		return nil, &GatherError{Path: IncludeFileName, Op: "load", Err: err}
+	}
+
+	if found {
+		filters.includeFile = includeParser
+	}
+
+	if fg.config.SinceTag != "" {
+		filters.changedOnly, err = changedFilesSince(ctx, fg.rootPath, fg.config.SinceTag, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(filters.changedOnly) == 0 {
+			fg.logger.Warn("No files changed since tag; output will be empty", zap.String("tag", fg.config.SinceTag))
+		}
+	}
+
+	if fg.config.Since != "" {
+		if filters.changedOnly, err = fg.changedSinceRef(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return filters, nil
+}
+
+// stripPathPrefix removes the literal --strip-prefix string from the output paths of the files.
+// A path that would become empty, start with "..", or equal the path of another file keeps its
+// original value, with a warning.
+func (fg *FileGatherer) stripPathPrefix(files []FileInfo) {
+	prefix := fg.config.StripPrefix
+
+	strip := func(path string) (string, bool) {
+		stripped, found := strings.CutPrefix(path, prefix)
+		if !found {
+			return path, true
+		}
+
+		stripped = strings.TrimLeft(stripped, "/"+string(filepath.Separator))
+		if stripped == "" || stripped == ".." || strings.HasPrefix(stripped, ".."+string(filepath.Separator)) {
+			return path, false
+		}
+
+		return stripped, true
+	}
+
+	renamed := make(map[string]string, len(files))
+
+	for _, file := range files {
+		stripped, ok := strip(file.Path)
+		if !ok {
+			fg.logger.Warn("Keeping the original path; stripping the prefix would leave it empty or outside the root",
+				zap.String("path", file.Path), zap.String("strip_prefix", prefix))
+		}
+
+		renamed[file.Path] = stripped
+	}
+
+	// Restoring a colliding path can make it collide with another stripped path, so repeat until none do.
+	for collided := true; collided; {
+		collided = false
+		owners := make(map[string][]string, len(renamed))
+
+		for original, path := range renamed {
+			owners[path] = append(owners[path], original)
+		}
+
+		for path, originals := range owners {
+			if len(originals) == 1 {
+				continue
+			}
+
+			for _, original := range originals {
+				if original != path {
+					fg.logger.Warn("Keeping the original path; stripping the prefix would give it the path of another file",
+						zap.String("path", original), zap.String("stripped", path))
+
+					renamed[original] = original
+					collided = true
+				}
+			}
+		}
+	}
+
+	for i := range files {
+		files[i].Path = renamed[files[i].Path]
+
+		for j, other := range files[i].SameAs {
+			if path, ok := renamed[other]; ok {
+				files[i].SameAs[j] = path
+			} else {
+				files[i].SameAs[j], _ = strip(other)
+			}
+		}
+	}
+}
+
+// changedSinceRef returns the files changed on HEAD since it diverged from the Since ref,
+// resolving SinceDefaultBranch to the repository's default branch.
+func (fg *FileGatherer) changedSinceRef(ctx context.Context) (map[string]bool, error) {
+	ref := fg.config.Since
+	if ref == config.SinceDefaultBranch {
+		var err error
+		if ref, err = DefaultBranch(ctx, fg.rootPath); err != nil {
+			return nil, err
+		}
+
+		fg.logger.Info("Comparing against the default branch", zap.String("ref", ref))
+	}
+
+	changed, err := changedFilesSince(ctx, fg.rootPath, ref, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changed) == 0 {
+		fg.logger.Warn("No files changed since ref; output will be empty", zap.String("ref", ref))
+	}
+
+	return changed, nil
+}
+
+// waitForRead blocks until the read limiter allows reading size bytes.
+// Sizes larger than the limiter's burst are reserved in burst-sized chunks.
+func (fg *FileGatherer) waitForRead(ctx context.Context, size int64) error {
+	if fg.readLimiter == nil {
+		return nil
+	}
+
+	burst := int64(fg.readLimiter.Burst())
+	for size > 0 {
+		n := min(size, burst)
+		if err := fg.readLimiter.WaitN(ctx, int(n)); err != nil {
+			return err
+		}
+
+		size -= n
+	}
+
+	return nil
+}
+
 func (fg *FileGatherer) prepareExtensionFilters() (extInclude, extExclude map[string]bool) {
 	extInclude = make(map[string]bool)
 	extExclude = make(map[string]bool)
 
-	if len(fg.config.IncludeExt) == 0 {
+	if len(fg.config.IncludeExt) == 0 && !fg.config.NoDefaultExcludes {
 		for _, ext := range config.DefaultExtensions() {
 			extInclude[ext] = true
 		}
@@ -226,36 +930,102 @@ func (fg *FileGatherer) prepareExtensionFilters() (extInclude, extExclude map[st
 		extExclude[ext] = true
 	}
 
+	// Priority files such as go.mod join the default extensions, unless excluded by name
+	// or extension; an explicit include list decides on them like on any other file.
+	if len(fg.config.IncludeExt) == 0 && !fg.config.NoDefaultExcludes {
+		for _, name := range fg.config.PriorityFiles {
+			if !extExclude[name] && !extExclude[filepath.Ext(name)] {
+				extInclude[name] = true
+			}
+		}
+	}
+
+	if fg.config.NoDefaultExcludes {
+		return extInclude, extExclude
+	}
+
+	lockfiles := make(map[string]bool)
+	for _, file := range config.DefaultLockfiles() {
+		lockfiles[file] = true
+	}
+
 	for _, file := range config.DefaultExcludeFiles() {
+		if fg.config.KeepLockfiles && lockfiles[file] {
+			continue
+		}
+
 		extExclude[file] = true
 	}
 
 	return extInclude, extExclude
 }
 
+// gitignored reports whether path is ignored by the loaded ignore files.
+// Directories are checked against directory-only patterns too, so an ignored tree is skipped as a whole.
+func (fg *FileGatherer) gitignored(path string, isDir bool) bool {
+	if isDir {
+		return fg.gitignoreParser.ShouldIgnoreDir(path)
+	}
+
+	return fg.gitignoreParser.ShouldIgnore(path)
+}
+
 // prepareDirFilters now chooses which exclusion list to use.
-func (fg *FileGatherer) prepareDirFilters(gitignoreExists bool) map[string]bool {
-	dirExclude := make(map[string]bool)
+func (fg *FileGatherer) prepareDirFilters(gitignoreExists bool) (*dirFilter, error) {
+	dirExclude := &dirFilter{names: make(map[string]bool)}
 
 	var defaultDirs []string
 
-	if gitignoreExists {
-		// .gitignore exists, so be minimal. Only exclude VCS directories.
+	switch {
+	case fg.config.NoDefaultExcludes, fg.config.NoDefaultExcludeDirs:
+		// Only user-provided exclusions (and the ignore files) apply.
+	case gitignoreExists:
+		// .gitignore (or .hgignore) exists, so be minimal. Only exclude VCS directories.
 		defaultDirs = []string{".git", ".svn", ".hg"}
-	} else {
+	default:
 		// No .gitignore, so use the comprehensive "helpful" list.
 		defaultDirs = config.DefaultExcludeDirs()
 	}
 
 	for _, dir := range defaultDirs {
-		dirExclude[dir] = true
+		dirExclude.names[dir] = true
 	}
-	// Always add user-provided exclusions from the command line.
+
+	// Always add user-provided exclusions from the command line. Entries with glob
+	// metacharacters are matched as patterns against directory names.
+	var patterns []string
+
 	for _, dir := range fg.config.ExcludeDirs {
-		dirExclude[dir] = true
+		if strings.ContainsAny(dir, "*?[") {
+			patterns = append(patterns, dir)
+		} else {
+			dirExclude.names[dir] = true
+		}
 	}
 
-	return dirExclude
+	var err error
+	if dirExclude.patterns, err = CompileGlobSet(patterns); err != nil {
+		return nil, fmt.Errorf("invalid exclude-dirs pattern: %w", err)
+	}
+
+	return dirExclude, nil
+}
+
+// dirFilter holds the directory names, exact or as glob patterns, whose trees are pruned.
+type dirFilter struct {
+	names    map[string]bool
+	patterns GlobSet
+}
+
+// excludes reports whether a directory with the given name is pruned.
+func (df *dirFilter) excludes(name string) bool {
+	return df.names[name] || df.patterns.MatchPath(name)
+}
+
+// shouldSkipHiddenDir reports whether a hidden directory tree is pruned. SkipHiddenDirs
+// prunes them even when IncludeHidden lets hidden files through.
+func (fg *FileGatherer) shouldSkipHiddenDir(name string) bool {
+	return strings.HasPrefix(name, ".") && (!fg.config.IncludeHidden || fg.config.SkipHiddenDirs)
 }
 
 func (fg *FileGatherer) shouldSkipHidden(name string) bool {
@@ -270,6 +1040,10 @@ func (fg *FileGatherer) shouldIncludeFile(path string, extInclude, extExclude ma
 		return false
 	}
 
+	if extInclude[fileName] {
+		return true
+	}
+
 	if fg.config.IncludeHidden && strings.HasPrefix(fileName, ".") {
 		if ext != "" && extExclude[ext] {
 			return false
@@ -282,6 +1056,11 @@ func (fg *FileGatherer) shouldIncludeFile(path string, extInclude, extExclude ma
 		return true
 	}
 
+	// An empty include set (only possible with NoDefaultExcludes) includes everything not excluded.
+	if len(extInclude) == 0 {
+		return ext == "" || !extExclude[ext]
+	}
+
 	if ext == "" {
 		return extInclude[fileName]
 	}
@@ -290,6 +1069,10 @@ func (fg *FileGatherer) shouldIncludeFile(path string, extInclude, extExclude ma
 }
 
 func isBinary(data []byte) bool {
+	if knownBinaryMagic(data) {
+		return true
+	}
+
 	for _, b := range data {
 		if b == 0 {
 			return true
@@ -311,3 +1094,26 @@ func isBinary(data []byte) bool {
 
 	return false
 }
+
+// knownBinaryMagic reports whether data starts with the magic number of a common binary
+// format. Short files of these formats can have too few null or control bytes to be
+// caught by the content heuristics in isBinary.
+func knownBinaryMagic(data []byte) bool {
+	magics := []string{
+		"\x00asm",          // WebAssembly
+		"\xCA\xFE\xBA\xBE", // Java class
+		"\x89PNG",          // PNG
+		"\xFF\xD8",         // JPEG
+		"PK\x03\x04",       // ZIP (and JAR, DOCX, ...)
+		"PK\x05\x06",       // Empty ZIP
+		"PK\x07\x08",       // Spanned ZIP
+	}
+
+	for _, magic := range magics {
+		if bytes.HasPrefix(data, []byte(magic)) {
+			return true
+		}
+	}
+
+	return false
+}
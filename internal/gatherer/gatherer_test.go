@@ -3,11 +3,23 @@ package gatherer
 import (
 	"code2md/internal/config"
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // assertFilePathsMatch is a helper function to compare the gathered file paths with an expected list.
@@ -35,33 +47,23 @@ func assertFilePathsMatch(t *testing.T, files []FileInfo, expected []string) {
 }
 
 func TestFileGatherer_GatherFiles_WithGitignore(t *testing.T) {
-	tmpDir := t.TempDir()
 	logger, _ := zap.NewDevelopment()
 
-	createTestFile := func(filePath string, content string) {
-		fullPath := filepath.Join(tmpDir, filePath)
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			t.Fatalf("Failed to create directory for %s: %v", fullPath, err)
-		}
-		// Corrected file permissions to satisfy gosec linter.
-		if err := os.WriteFile(fullPath, []byte(content), 0600); err != nil {
-			t.Fatalf("Failed to write file %s: %v", fullPath, err)
-		}
-	}
-
-	createTestFile(".gitignore", "*.log\nbuild/\ntemp.txt\n")
-	createTestFile("main.go", "package main")
-	createTestFile("README.md", "# Test")
-	createTestFile("debug.log", "log content")
-	createTestFile("build/output.txt", "build output")
-	createTestFile("temp.txt", "temporary file")
-	createTestFile("config.yaml", "key: value")
+	fsys := newMapFS(map[string]string{
+		".gitignore":       "*.log\nbuild/\ntemp.txt\n",
+		"main.go":          "package main",
+		"README.md":        "# Test",
+		"debug.log":        "log content",
+		"build/output.txt": "build output",
+		"temp.txt":         "temporary file",
+		"config.yaml":      "key: value",
+	})
 
 	cfg := &config.Config{
 		MaxFileSize:   1024 * 1024,
 		IncludeHidden: false,
 	}
-	gatherer := NewFileGatherer(cfg, tmpDir, logger)
+	gatherer := NewFileGathererFromMapFS(cfg, fsys, testRoot, logger)
 
 	files, err := gatherer.GatherFiles(context.Background())
 	if err != nil {
@@ -73,20 +75,8 @@ func TestFileGatherer_GatherFiles_WithGitignore(t *testing.T) {
 }
 
 func TestFileGatherer_GitignoreComplexPatterns(t *testing.T) {
-	tmpDir := t.TempDir()
 	logger, _ := zap.NewDevelopment()
 
-	createTestFile := func(filePath string, content string) {
-		fullPath := filepath.Join(tmpDir, filePath)
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			t.Fatalf("Failed to create directory for %s: %v", fullPath, err)
-		}
-		// Corrected file permissions to satisfy gosec linter.
-		if err := os.WriteFile(fullPath, []byte(content), 0600); err != nil {
-			t.Fatalf("Failed to write file %s: %v", fullPath, err)
-		}
-	}
-
 	gitignoreContent := `
 # Ignore all .log files
 *.log
@@ -97,19 +87,21 @@ docs/
 # Ignore files in any 'tmp' directory
 **/tmp/data.txt
 `
-	createTestFile(".gitignore", gitignoreContent)
-	createTestFile("main.go", "package main")
-	createTestFile("src/build/somefile.txt", "not in root build dir")
-	createTestFile("debug.log", "log content")
-	createTestFile("build/output.txt", "in root build dir")
-	createTestFile("src/docs/guide.md", "in a docs dir")
-	createTestFile("app/tmp/data.txt", "in a tmp dir")
+	fsys := newMapFS(map[string]string{
+		".gitignore":             gitignoreContent,
+		"main.go":                "package main",
+		"src/build/somefile.txt": "not in root build dir",
+		"debug.log":              "log content",
+		"build/output.txt":       "in root build dir",
+		"src/docs/guide.md":      "in a docs dir",
+		"app/tmp/data.txt":       "in a tmp dir",
+	})
 
 	cfg := &config.Config{
 		MaxFileSize:   1024 * 1024,
 		IncludeHidden: false,
 	}
-	gatherer := NewFileGatherer(cfg, tmpDir, logger)
+	gatherer := NewFileGathererFromMapFS(cfg, fsys, testRoot, logger)
 
 	files, err := gatherer.GatherFiles(context.Background())
 	if err != nil {
@@ -119,3 +111,1540 @@ docs/
 	expectedFiles := []string{"main.go", "src/build/somefile.txt"}
 	assertFilePathsMatch(t, files, expectedFiles)
 }
+
+// writeTestFiles creates each file in the map (relative path -> content) under root.
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for filePath, content := range files {
+		fullPath := filepath.Join(root, filePath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", fullPath, err)
+		}
+
+		if err := os.WriteFile(fullPath, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write file %s: %v", fullPath, err)
+		}
+	}
+}
+
+func TestFileGatherer_ParentGitignoreInSubdirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		".gitignore":             "*.go\n", // Outside the repository; must not apply.
+		"repo/.git/HEAD":         "ref: refs/heads/main",
+		"repo/.gitignore":        "*.log\n/src/secret.go\n",
+		"repo/src/main.go":       "package main",
+		"repo/src/debug.log":     "log content",
+		"repo/src/secret.go":     "package main",
+		"repo/src/pkg/secret.go": "package pkg",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGatherer(cfg, filepath.Join(tmpDir, "repo", "src"), zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go", "pkg/secret.go"})
+}
+
+func TestFileGatherer_ProducerErrorSurfaces(t *testing.T) {
+	// The root still stats but cannot be listed, so the walk fails inside the producer
+	// while the workers are running.
+	fsys := vanishingFS{
+		MapFS:   newMapFS(map[string]string{"main.go": "package main"}),
+		removed: map[string]bool{".": true},
+	}
+
+	files, err := NewFileGatherer(&config.Config{MaxFileSize: 1024}, testRoot, zap.NewNop(), withFS(fsys)).
+		GatherFiles(context.Background())
+
+	var gatherErr *GatherError
+	if !errors.As(err, &gatherErr) || gatherErr.Op != "walk" || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected GatherFiles() to return the producer's walk error, got files %v and error %v", files, err)
+	}
+}
+
+func TestFileGatherer_ProducerStopsOnCancel(t *testing.T) {
+	fg := NewFileGathererFromMapFS(&config.Config{MaxFileSize: 1024}, newMapFS(map[string]string{"main.go": "package main"}),
+		testRoot, zap.NewNop())
+
+	dirExclude, err := fg.prepareDirFilters(false)
+	if err != nil {
+		t.Fatalf("prepareDirFilters() returned an unexpected error: %v", err)
+	}
+
+	// With no worker receiving, the producer blocks sending main.go until the context
+	// is canceled, as happens when every worker has stopped on an error.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- fg.producer(ctx, make(chan string), dirExclude, nil)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the producer to return context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the producer to stop on cancellation instead of blocking on its send")
+	}
+}
+
+func TestFileGatherer_MissingRootIsGatherError(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+
+	_, err := NewFileGatherer(&config.Config{MaxFileSize: 1024}, root, zap.NewNop()).GatherFiles(context.Background())
+
+	var gatherErr *GatherError
+	if !errors.As(err, &gatherErr) || gatherErr.Op != "walk" || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected a walk GatherError wrapping fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestFileGatherer_StatsCountsSkippedDirs(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		".gitignore":       "build/\n",
+		"main.go":          "package main",
+		"build/output.go":  "package build",
+		".git/config":      "[core]",
+		"vendor/lib/a.go":  "package lib",
+		"src/helper.go":    "package src",
+		"src/.cache/x.txt": "cached",
+	})
+
+	cfg := &config.Config{
+		MaxFileSize: 1024 * 1024,
+		ExcludeDirs: []string{"vendor"},
+	}
+	gatherer := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop())
+
+	files, err := gatherer.GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go", "src/helper.go"})
+
+	// build (gitignore), .git (VCS), vendor (user exclusion), src/.cache (hidden).
+	if got := gatherer.Stats().SkippedDirs; got != 4 {
+		t.Errorf("Expected 4 skipped directories, got %d", got)
+	}
+
+	// .gitignore is visited but hidden.
+	if got := gatherer.Stats().SkippedFiles; got != 1 {
+		t.Errorf("Expected 1 skipped file, got %d", got)
+	}
+}
+
+func TestFileGatherer_ExcludeGenerated(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":                         "package main",
+		"api/service.pb.go":               "package api",
+		"models/zz_generated.deepcopy.go": "package models",
+		"web/app.min.js":                  "var a=1;",
+		"web/app.js":                      "var a = 1;",
+	})
+
+	cfg := &config.Config{
+		MaxFileSize:      1024 * 1024,
+		ExcludeGenerated: true,
+	}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go", "web/app.js"})
+}
+
+func TestFileGatherer_DefaultExcludeDirsWithoutGitignore(t *testing.T) {
+	files := map[string]string{
+		"main.go":              "package main",
+		"node_modules/x/x.js":  "module.exports = {};",
+		"vendor/lib/lib.go":    "package lib",
+		"internal/app/app.go":  "package app",
+		"internal/app/app.js":  "export {};",
+		"build/generated.go":   "package build",
+		"docs/guide/readme.md": "# Guide",
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go", ".js"}}
+
+	// Without a .gitignore, the built-in list of directories applies.
+	gathered, err := NewFileGathererFromMapFS(cfg, newMapFS(files), testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, gathered, []string{"internal/app/app.go", "internal/app/app.js", "main.go"})
+
+	// With one, only the VCS directories are excluded by default.
+	files[".gitignore"] = "*.log\n"
+
+	gathered, err = NewFileGathererFromMapFS(cfg, newMapFS(files), testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, gathered, []string{
+		"build/generated.go", "internal/app/app.go", "internal/app/app.js", "main.go", "node_modules/x/x.js", "vendor/lib/lib.go",
+	})
+}
+
+func TestFileGatherer_NpmOnly(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		".gitignore":          "node_modules/\n",
+		"package.json":        `{"name": "pkg", "files": ["dist/", "index.js"]}`,
+		".npmignore":          "*.map\n",
+		"index.js":            "module.exports = {};",
+		"dist/lib.js":         "export {};",
+		"dist/lib.js.map":     "{}",
+		"src/lib.ts":          "export {};",
+		"src/nested/index.js": "module.exports = {};",
+	})
+
+	cfg := &config.Config{
+		MaxFileSize: 1024 * 1024,
+		IncludeExt:  []string{".js", ".json", ".ts", ".map"},
+		NpmIgnore:   true,
+		NpmOnly:     true,
+	}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"dist/lib.js", "index.js", "package.json"})
+}
+
+func TestFileGatherer_IgnoreFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"index.js":          "module.exports = {};",
+		"lib/util.js":       "export {};",
+		"lib/util.test.js":  "test();",
+		"fixtures/data.js":  "export {};",
+		".eslintignore":     "*.test.js\n",
+		"config/extra.conf": "key = value",
+	})
+
+	customIgnore := filepath.Join(t.TempDir(), "custom-ignore")
+	if err := os.WriteFile(customIgnore, []byte("fixtures/\n*.conf\n"), 0600); err != nil {
+		t.Fatalf("Failed to write custom ignore file: %v", err)
+	}
+
+	cfg := &config.Config{
+		MaxFileSize: 1024 * 1024,
+		IgnoreFiles: []string{filepath.Join(tmpDir, ".eslintignore"), customIgnore},
+	}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"index.js", "lib/util.js"})
+}
+
+func TestFileGatherer_MaxReadBytesPerSec(t *testing.T) {
+	content := strings.Repeat("a", 500)
+	fsys := newMapFS(map[string]string{
+		"a.txt": content,
+		"b.txt": content,
+		"c.txt": content,
+		"d.txt": content,
+	})
+
+	const limit = 1000
+
+	cfg := &config.Config{
+		MaxFileSize:        1024 * 1024,
+		MaxReadBytesPerSec: limit,
+	}
+
+	start := time.Now()
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	elapsed := time.Since(start)
+
+	assertFilePathsMatch(t, files, []string{"a.txt", "b.txt", "c.txt", "d.txt"})
+
+	// 2000 bytes at 1000 B/s with a one-second burst should take at least ~1s.
+	const minExpected = 900 * time.Millisecond
+	if elapsed < minExpected {
+		t.Errorf("Expected throttled gathering to take at least %v, took %v", minExpected, elapsed)
+	}
+}
+
+func TestFileGatherer_MaxMemory(t *testing.T) {
+	files := make(map[string]string)
+	expected := make([]string, 0, 16)
+
+	for i := range 16 {
+		name := fmt.Sprintf("file%02d.txt", i)
+		files[name] = strings.Repeat(string(rune('a'+i)), 64*1024)
+		expected = append(expected, name)
+	}
+
+	previousLimit := debug.SetMemoryLimit(-1)
+
+	// A one-byte cap is always exceeded, so reads are serialized but must still all complete.
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, MaxMemory: 1}
+
+	gathered, err := NewFileGathererFromMapFS(cfg, newMapFS(files), testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, gathered, expected)
+
+	for _, file := range gathered {
+		if file.Text() != files[file.Path] {
+			t.Errorf("Content of %s does not match the file on disk", file.Path)
+		}
+	}
+
+	if limit := debug.SetMemoryLimit(-1); limit != previousLimit {
+		t.Errorf("Expected the runtime memory limit to be restored to %d, got %d", previousLimit, limit)
+	}
+}
+
+func TestFileGatherer_MaxMemoryKeepsLowerRuntimeLimit(t *testing.T) {
+	previousLimit := debug.SetMemoryLimit(1 << 30)
+	defer debug.SetMemoryLimit(previousLimit)
+
+	var limitWhileReading int64
+
+	// The filter runs while the file is gathered, so it sees the limit in effect then.
+	fg := NewFileGatherer(&config.Config{MaxFileSize: 1024, MaxMemory: 1 << 40}, testRoot, zap.NewNop(),
+		withFS(newMapFS(map[string]string{"a.go": "package a"})),
+		WithFilter(func(string, fs.FileInfo, []byte) (bool, string) {
+			limitWhileReading = debug.SetMemoryLimit(-1)
+			return true, ""
+		}))
+
+	if _, err := fg.GatherFiles(context.Background()); err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	if limitWhileReading != 1<<30 {
+		t.Errorf("Expected a higher cap to leave the runtime limit at %d, got %d", 1<<30, limitWhileReading)
+	}
+}
+
+func TestMemoryGate_SerializesReadsOverLimit(t *testing.T) {
+	gate := &memoryGate{limit: 1}
+
+	var (
+		wg             sync.WaitGroup
+		mu             sync.Mutex
+		inflight, peak int
+	)
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := gate.acquire(context.Background()); err != nil {
+				t.Errorf("acquire() returned an unexpected error: %v", err)
+				return
+			}
+
+			mu.Lock()
+			inflight++
+			peak = max(peak, inflight)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+			gate.release()
+		}()
+	}
+
+	wg.Wait()
+
+	if peak != 1 {
+		t.Errorf("Expected one read at a time above the limit, got a peak of %d", peak)
+	}
+}
+
+// runGitCmd runs a git command in dir, failing the test on error.
+func runGitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	base := []string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}
+
+	out, err := exec.Command("git", append(base, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestFileGatherer_SinceTag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"main.go":  "package main",
+		"util.go":  "package main",
+		"notes.md": "# Notes",
+	})
+
+	runGitCmd(t, tmpDir, "init", "-q")
+	runGitCmd(t, tmpDir, "add", ".")
+	runGitCmd(t, tmpDir, "commit", "-q", "-m", "initial")
+	runGitCmd(t, tmpDir, "tag", "v1.0.0")
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, SinceTag: "v1.0.0"}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{})
+
+	// git quotes non-ASCII paths unless they are listed NUL-terminated.
+	writeTestFiles(t, tmpDir, map[string]string{"util.go": "package main\n\nfunc util() {}", "héllo wörld.go": "package main"})
+	runGitCmd(t, tmpDir, "add", ".")
+	runGitCmd(t, tmpDir, "commit", "-q", "-m", "change util")
+
+	files, err = NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"héllo wörld.go", "util.go"})
+
+	cfg.SinceTag = "v9.9.9"
+	if _, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background()); !errors.Is(err, ErrUnknownRef) {
+		t.Errorf("Expected ErrUnknownRef for a missing tag, got %v", err)
+	}
+}
+
+func TestFileGatherer_KeepLockfiles(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"package.json":      `{"name": "pkg"}`,
+		"package-lock.json": `{"lockfileVersion": 3}`,
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"package.json"})
+
+	cfg.KeepLockfiles = true
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"package-lock.json", "package.json"})
+}
+
+func TestFileGatherer_DefaultLockfilesExcluded(t *testing.T) {
+	tree := map[string]string{"main.go": "package main\n"}
+	for _, name := range config.DefaultLockfiles() {
+		tree[name] = "lock\n"
+		tree["sub/"+name] = "lock\n"
+	}
+
+	fsys := newMapFS(tree)
+
+	files, err := NewFileGathererFromMapFS(config.NewConfig(), fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+
+	// Lockfiles are excluded by name, even when their extensions are included.
+	cfg := config.NewConfig()
+	cfg.IncludeExt = []string{".go", ".json", ".yaml", ".lock", ".lockb", ".sum"}
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+}
+
+func TestFileGatherer_NoDefaultExcludes(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":           "package main",
+		"data.bin.txt":      "data",
+		"LICENSE":           "MIT",
+		"package-lock.json": "{}",
+		"dist/bundle.js":    "var a;",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, NoDefaultExcludes: true}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"LICENSE", "data.bin.txt", "dist/bundle.js", "main.go", "package-lock.json"})
+
+	cfg.IncludeExt = []string{".go"}
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+}
+
+func TestLanguageFromPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Go file", "main.go", "go"},
+		{"Dockerfile", "Dockerfile", "dockerfile"},
+		{"Lua file", "init.lua", "lua"},
+		{"Perl script", "build.pl", "perl"},
+		{"Perl module", "lib/Util.pm", "perl"},
+		{"COBOL file", "payroll.cob", "cobol"},
+		{"COBOL copybook", "record.cbl", "cobol"},
+		{"Fortran 90 file", "solver.f90", "fortran"},
+		{"Fortran 95 file", "solver.f95", "fortran"},
+		{"Fixed-form Fortran file", "legacy.for", "fortran"},
+		{"Ada body", "main.adb", "ada"},
+		{"Ada spec", "main.ads", "ada"},
+		{"Dotenv file", ".env", "dotenv"},
+		{"Dotenv example", ".env.example", "dotenv"},
+		{"Dotenv extension", "config/app.env", "dotenv"},
+		{"Bicep file", "infra/main.bicep", "bicep"},
+		{"Bicep parameters", "infra/main.bicepparam", "bicep"},
+		{"ARM template", "infra/azuredeploy.arm.json", "json"},
+		{"Jenkinsfile", "Jenkinsfile", "groovy"},
+		{"Azure Pipelines", "azure-pipelines.yml", "yaml"},
+		{"Snakefile", "workflow/Snakefile", "python"},
+		{"Snakemake rules", "rules/align.smk", "python"},
+		{"Nextflow script", "main.nf", "groovy"},
+		{"Nextflow config", "nextflow.config", "groovy"},
+		{"Protobuf", "api/service.proto", "protobuf"},
+		{"GraphQL", "schema.graphql", "graphql"},
+		{"GraphQL short", "queries/user.gql", "graphql"},
+		{"Thrift", "service.thrift", "thrift"},
+		{"Avro schema", "user.avsc", "json"},
+		{"Prisma", "prisma/schema.prisma", "prisma"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := LanguageFromPath(tc.path)
+			if actual != tc.expected {
+				t.Errorf("LanguageFromPath(%q): expected %q, got %q", tc.path, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFileGatherer_Languages(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":   "package main",
+		"script.py": "print('hi')",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, Languages: []string{"go"}}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+
+	if files[0].Language != "go" {
+		t.Errorf("Expected gathered file language to be %q, got %q", "go", files[0].Language)
+	}
+}
+
+func TestFileGatherer_DotenvTemplates(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":      "package main",
+		".env":         "SECRET=1",
+		".env.example": "SECRET=",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{".env.example", "main.go"})
+}
+
+func TestFileGatherer_CIWorkflows(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":                  "package main",
+		".github/workflows/ci.yml": "on: push",
+		".gitlab-ci.yml":           "stages: [test]",
+		"Jenkinsfile":              "pipeline {}",
+		"azure-pipelines.yml":      "trigger: [main]",
+		".cache/state.yml":         "cached: true",
+		".vscode/settings.json":    "{}",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{
+		".github/workflows/ci.yml", ".gitlab-ci.yml", "Jenkinsfile", "azure-pipelines.yml", "main.go",
+	})
+}
+
+func TestFileGatherer_WarnsOnUnmatchedInclude(t *testing.T) {
+	fsys := newMapFS(map[string]string{"main.go": "package main"})
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go", ".rs"}}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.New(core)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+
+	warnings := logs.FilterMessage("Include filter matched 0 files").All()
+	if len(warnings) != 1 || warnings[0].ContextMap()["include"] != ".rs" {
+		t.Errorf("Expected a single matched-0-files warning for .rs, got %v", warnings)
+	}
+}
+
+func TestFileGatherer_NormalizesPathsToNFC(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	const (
+		composed   = "caf\u00e9"  // é as a single code point (NFC).
+		decomposed = "cafe\u0301" // e followed by a combining acute accent (NFD).
+	)
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		composed + "/menu.md":         "# Menu",
+		"notes/" + decomposed + ".md": "# Notes",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{composed + "/menu.md", "notes/" + composed + ".md"})
+}
+
+func TestFileGatherer_SkipHiddenDirsKeepsDotfiles(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":         "package main",
+		".env":            "DEBUG=1",
+		".cache/state.go": "package cache",
+		".git/config":     "[core]",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeHidden: true, SkipHiddenDirs: true}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{".env", "main.go"})
+
+	cfg.SkipHiddenDirs = false
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{".cache/state.go", ".env", "main.go"})
+}
+
+func TestReadStdinFile(t *testing.T) {
+	testCases := []struct {
+		lang     string
+		expected string
+	}{
+		{"go", "go"},
+		{".py", "python"},
+		{"elixir", "elixir"},
+	}
+
+	for _, tc := range testCases {
+		file, err := ReadStdinFile(strings.NewReader("content\n"), tc.lang)
+		if err != nil {
+			t.Fatalf("ReadStdinFile(%q) returned an unexpected error: %v", tc.lang, err)
+		}
+
+		if file.Path != StdinPath || file.Size != 8 || file.Text() != "content\n" {
+			t.Errorf("ReadStdinFile(%q): unexpected file %+v", tc.lang, file)
+		}
+
+		if file.Language != tc.expected {
+			t.Errorf("ReadStdinFile(%q): expected language %q, got %q", tc.lang, tc.expected, file.Language)
+		}
+	}
+}
+
+func TestFileGatherer_FailOnLargeFile(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":      "package main",
+		"big/data.go":  strings.Repeat("x", 64),
+		"huge_test.go": strings.Repeat("y", 64),
+	})
+
+	cfg := &config.Config{MaxFileSize: 32}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+
+	cfg.FailOnLargeFile = true
+
+	_, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if !errors.Is(err, ErrLargeFiles) {
+		t.Fatalf("Expected ErrLargeFiles, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "big/data.go, huge_test.go") {
+		t.Errorf("Expected the error to list the large files, got %q", err.Error())
+	}
+}
+
+func TestFileGatherer_IncludeFile(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		IncludeFileName:     "# Only the sources\nsrc/**\n",
+		"main.go":           "package main",
+		"src/app.go":        "package src",
+		"src/util/util.go":  "package util",
+		"src/notes.bin":     "skipped by extension",
+		"docs/src/guide.md": "# Guide",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"src/app.go", "src/util/util.go"})
+}
+
+func TestFileGatherer_RelativizeSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"a.go": "package a", "c.go": "package c"})
+
+	if err := os.Symlink("a.go", filepath.Join(tmpDir, "b.go")); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, RelativizeSymlinks: true}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"a.go", "b.go", "c.go"})
+
+	expected := map[string]string{"a.go": "b.go", "b.go": "a.go", "c.go": ""}
+	for _, file := range files {
+		if got := strings.Join(file.SameAs, ","); got != expected[file.Path] {
+			t.Errorf("Expected %s to be the same file as %q, got %q", file.Path, expected[file.Path], got)
+		}
+	}
+
+	if files[1].RealPath != "a.go" {
+		t.Errorf("Expected b.go to resolve to a.go, got %q", files[1].RealPath)
+	}
+}
+
+func TestFileGatherer_DryRun(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		".gitignore":   "*.log\n",
+		"main.go":      "package main",
+		"debug.log":    "log content",
+		"pkg/util.go":  "package pkg",
+		"docs/read.md": "# Docs",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+	g := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop())
+
+	gathered, err := g.GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	stats, err := g.DryRun(context.Background())
+	if err != nil {
+		t.Fatalf("DryRun() returned an unexpected error: %v", err)
+	}
+
+	if len(stats.Files) != len(gathered) {
+		t.Fatalf("Expected DryRun to match %d files, got %d", len(gathered), len(stats.Files))
+	}
+
+	for i, file := range stats.Files {
+		if file.Path != gathered[i].Path || file.Size != gathered[i].Size {
+			t.Errorf("Expected %s (%d bytes), got %s (%d bytes)", gathered[i].Path, gathered[i].Size, file.Path, file.Size)
+		}
+
+		if file.Text() != "" || file.ModTime.IsZero() {
+			t.Errorf("Expected %s to have a modification time but no content", file.Path)
+		}
+	}
+
+	if g.Stats().SkippedFiles != stats.SkippedFiles {
+		t.Errorf("Expected Stats() to report the dry run, got %+v", g.Stats())
+	}
+}
+
+func TestFileGatherer_ExcludeDirPatterns(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":              "package main",
+		"build-linux/out.go":   "package out",
+		"build-darwin/out.go":  "package out",
+		"builder/builder.go":   "package builder",
+		"cache1/entry.go":      "package cache",
+		"cache22/entry.go":     "package cache",
+		"pkg/tmp_a/scratch.go": "package tmp",
+		"docs/guide.md":        "# Guide",
+	})
+
+	cfg := &config.Config{
+		MaxFileSize: 1024 * 1024,
+		ExcludeDirs: []string{"build-*", "cache?", "tmp_[a-z]", "docs"},
+	}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"builder/builder.go", "cache22/entry.go", "main.go"})
+
+	cfg.ExcludeDirs = []string{"build-["}
+
+	if _, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background()); err == nil {
+		t.Error("Expected an invalid exclude-dirs pattern to be reported")
+	}
+}
+
+func TestFileGatherer_GitInfoExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		".git/info/exclude": "# local ignores\nscratch/\n*.local.go\n",
+		"main.go":           "package main",
+		"debug.local.go":    "package main",
+		"scratch/notes.md":  "# Notes",
+		"pkg/lib.go":        "package pkg",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	files, err := NewFileGatherer(cfg, filepath.Join(tmpDir, "pkg"), zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"lib.go"})
+
+	files, err = NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go", "pkg/lib.go"})
+
+	cfg.NoGitInfoExclude = true
+
+	files, err = NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"debug.local.go", "main.go", "pkg/lib.go", "scratch/notes.md"})
+}
+
+func TestFileGatherer_RelativeTo(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"src/main.go": "package main", "src/util/util.go": "package util"})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, RelativeTo: tmpDir}
+
+	files, err := NewFileGatherer(cfg, filepath.Join(tmpDir, "src"), zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"src/main.go", "src/util/util.go"})
+
+	cfg.RelativeTo = filepath.Join(tmpDir, "src", "util")
+
+	files, err = NewFileGatherer(cfg, filepath.Join(tmpDir, "src"), zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"../main.go", "util.go"})
+}
+
+func TestFileGatherer_MaxFileTokens(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"dense.go":  strings.Repeat("(){};", 12) + "\n", // 61 bytes, 60 symbol tokens.
+		"sparse.go": strings.Repeat("        x\n", 40),  // 400 bytes, 40 word tokens.
+	})
+
+	if dense, sparse := EstimateContentTokens([]byte(strings.Repeat("(){};", 12))),
+		EstimateContentTokens([]byte(strings.Repeat("        x\n", 40))); dense <= sparse {
+		t.Fatalf("Expected dense content to estimate more tokens than sparse content, got %d and %d", dense, sparse)
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, MaxFileTokens: 50}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"sparse.go"})
+}
+
+func TestFileGatherer_SchemaFiles(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"api/service.proto":    "syntax = \"proto3\";\n",
+		"schema.graphql":       "type Query { me: User }\n",
+		"prisma/schema.prisma": "model User { id Int @id }\n",
+	})
+
+	files, err := NewFileGathererFromMapFS(config.NewConfig(), fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"api/service.proto", "prisma/schema.prisma", "schema.graphql"})
+
+	if files[0].Language != "protobuf" {
+		t.Errorf("Expected service.proto to be detected as protobuf, got %q", files[0].Language)
+	}
+}
+
+func TestFileGatherer_WithFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"keep.go":      "package keep",
+		"generated.go": "// Code generated by hand. DO NOT EDIT.\npackage gen",
+		"notes.txt":    "DO NOT EDIT, but not Go either",
+	})
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+
+	filter := func(path string, info fs.FileInfo, content []byte) (bool, string) {
+		mu.Lock()
+		seen = append(seen, info.Name())
+		mu.Unlock()
+
+		if strings.Contains(string(content), "DO NOT EDIT") && filepath.Ext(path) == ".go" {
+			return false, "generated"
+		}
+
+		return true, ""
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go"}}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop(), WithFilter(filter)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"keep.go"})
+
+	if slices.Contains(seen, "notes.txt") {
+		t.Errorf("Expected the filter to run only on files passing the built-in checks, got %v", seen)
+	}
+}
+
+func TestDefaultBranchAndSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"main.go": "package main", "util.go": "package main"})
+
+	runGitCmd(t, tmpDir, "init", "-q", "-b", "main")
+
+	if _, err := DefaultBranch(context.Background(), tmpDir); !errors.Is(err, ErrNoDefaultBranch) {
+		t.Errorf("Expected ErrNoDefaultBranch before the first commit, got %v", err)
+	}
+
+	runGitCmd(t, tmpDir, "add", ".")
+	runGitCmd(t, tmpDir, "commit", "-q", "-m", "initial")
+
+	branch, err := DefaultBranch(context.Background(), tmpDir)
+	if err != nil || branch != "main" {
+		t.Fatalf("Expected default branch main, got %q (%v)", branch, err)
+	}
+
+	runGitCmd(t, tmpDir, "checkout", "-q", "-b", "feature")
+	writeTestFiles(t, tmpDir, map[string]string{"util.go": "package main\n\nfunc util() {}"})
+	runGitCmd(t, tmpDir, "commit", "-q", "-am", "change util")
+
+	// A later commit on main must not show up, since changes are taken from the merge base.
+	runGitCmd(t, tmpDir, "checkout", "-q", "main")
+	writeTestFiles(t, tmpDir, map[string]string{"main.go": "package main\n\nfunc main() {}"})
+	runGitCmd(t, tmpDir, "commit", "-q", "-am", "change main")
+	runGitCmd(t, tmpDir, "checkout", "-q", "feature")
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, Since: config.SinceDefaultBranch}
+
+	files, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"util.go"})
+}
+
+func TestFileGatherer_SkipWhitespaceOnly(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"blank.go": "  \n\t\n\n",
+		"empty.go": "",
+		"one.go":   " x \n",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, SkipWhitespaceOnly: true}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"empty.go", "one.go"})
+}
+
+func TestFileGatherer_DirOnlyGitignorePatterns(t *testing.T) {
+	files := map[string]string{
+		".gitignore":        "node_modules/\ncache/\nMakefile/\n",
+		"main.go":           "package main",
+		"Makefile":          "all:",  // Kept: Makefile/ only matches a directory named Makefile.
+		"cache":             "stale", // Kept: cache/ only matches directories, like src/cache.
+		"src/cache/data.go": "package cache",
+	}
+
+	for i := range 50 {
+		files[fmt.Sprintf("node_modules/pkg%d/index.go", i)] = "package pkg"
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go", "Makefile", "cache"}}
+	fg := NewFileGathererFromMapFS(cfg, newMapFS(files), testRoot, zap.NewNop())
+
+	gathered, err := fg.GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, gathered, []string{"Makefile", "cache", "main.go"})
+
+	// node_modules and src/cache are pruned as whole trees rather than file by file.
+	if got := fg.Stats().SkippedDirs; got != 2 {
+		t.Errorf("SkippedDirs = %d, want 2", got)
+	}
+}
+
+func BenchmarkGatherFiles_IgnoredDirectory(b *testing.B) {
+	tmpDir := b.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, ".gitignore"), []byte("node_modules/\n"), 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main"), 0o600); err != nil {
+		b.Fatal(err)
+	}
+
+	for i := range 100 {
+		dir := filepath.Join(tmpDir, "node_modules", fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			b.Fatal(err)
+		}
+
+		for j := range 20 {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.go", j)), []byte("package pkg"), 0o600); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	for b.Loop() {
+		if _, err := NewFileGatherer(cfg, tmpDir, zap.NewNop()).GatherFiles(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFileGatherer_StripPrefix(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"services/api/main.go":    "package main",
+		"services/api/handler.go": "package main",
+		"services/api.go":         "package services",
+		"tools/gen.go":            "package tools",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, StripPrefix: "services/api"}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	// "services/api.go" would become ".go"; "tools/gen.go" does not start with the prefix.
+	assertFilePathsMatch(t, files, []string{".go", "handler.go", "main.go", "tools/gen.go"})
+
+	cfg.StripPrefix = "tools/gen.go"
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"services/api.go", "services/api/handler.go", "services/api/main.go", "tools/gen.go"})
+}
+
+func TestFileGatherer_StripPrefixCollisions(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":                 "package main",
+		"services/api/main.go":    "package main",
+		"services/api/handler.go": "package main",
+		"x/services/api/main.go":  "package main",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, StripPrefix: "services/api/"}
+
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.New(core)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	// services/api/main.go would become main.go, which is taken by the root file.
+	assertFilePathsMatch(t, files, []string{"main.go", "handler.go", "services/api/main.go", "x/services/api/main.go"})
+
+	if logs.FilterMessageSnippet("path of another file").Len() != 1 {
+		t.Errorf("Expected one collision warning, got %v", logs.All())
+	}
+}
+
+func TestFileGatherer_Hgignore(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		".hg/store/data":   "",
+		".hgignore":        "# Default syntax is regexp.\n^out/\nsyntax: glob\n*_gen.go\nbuild\nsyntax: regexp\n\\.tmp\\.go$\nglob:scratch*\n",
+		"main.go":          "package main",
+		"api/api_gen.go":   "package api",
+		"api/api.go":       "package api",
+		"build/bin.go":     "package build",
+		"out/result.go":    "package out",
+		"lib/out/keep.go":  "package out",
+		"notes.tmp.go":     "package main",
+		"scratch_first.go": "package main",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024}
+
+	fg := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop())
+
+	files, err := fg.GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"api/api.go", "lib/out/keep.go", "main.go"})
+
+	sources := fg.IgnoreSources()
+	if len(sources) != 1 || sources[0].Path != HgignoreFileName || len(sources[0].Patterns) != 5 {
+		t.Errorf("Expected .hgignore with 5 patterns as the only ignore source, got %+v", sources)
+	}
+
+	// A git repository ignores .hgignore. Its .gitignore keeps the default directory
+	// exclusions, which would drop build and out, minimal.
+	fsys[".git/HEAD"] = &fstest.MapFile{}
+	fsys[".gitignore"] = &fstest.MapFile{}
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	if len(files) != 8 {
+		t.Errorf("Expected .hgignore to be ignored in a git repository, got %d files", len(files))
+	}
+}
+
+func TestFileGatherer_InvalidHgignoreKeepsDefaultExcludeDirs(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		".hg/store/data":      "",
+		".hgignore":           "syntax: fancy\n*.o\n",
+		"main.go":             "package main",
+		"node_modules/x/x.go": "package x",
+	})
+
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	files, err := NewFileGathererFromMapFS(&config.Config{MaxFileSize: 1024}, fsys, testRoot, zap.New(core)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"main.go"})
+
+	if logs.FilterMessage("Failed to load or parse .hgignore").Len() != 1 {
+		t.Errorf("Expected a warning about the invalid .hgignore, got %v", logs.All())
+	}
+}
+
+func TestHgignoreParser_UnknownSyntax(t *testing.T) {
+	_, err := NewHgignoreParser().Parse(strings.NewReader("syntax: fancy\n*.o\n"))
+	if !errors.Is(err, ErrUnknownHgignoreSyntax) {
+		t.Errorf("Expected ErrUnknownHgignoreSyntax, got %v", err)
+	}
+}
+
+func TestFileGatherer_PriorityFiles(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"a.go":             "package a",
+		"go.mod":           "module example.com/a\n\ngo 1.24\n",
+		"go.sum":           "example.com/b v1.0.0 h1:abc=\n",
+		"cmd/app/main.go":  "package main",
+		"web/package.json": `{"name": "web", "description": "` + strings.Repeat("x", 200) + `"}`,
+	})
+
+	// package.json exceeds MaxFileSize, and .mod is not a default extension.
+	cfg := &config.Config{MaxFileSize: 100, PriorityFiles: config.DefaultPriorityFiles()}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"go.mod", "web/package.json", "a.go", "cmd/app/main.go"})
+}
+
+func TestFileGatherer_PriorityFilesFollowExtensionFilters(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"a.go":             "package a",
+		"go.mod":           "module example.com/a\n",
+		"web/package.json": `{"name": "web"}`,
+		"Cargo.toml":       "[package]\n",
+	})
+
+	testCases := []struct {
+		name     string
+		cfg      *config.Config
+		expected []string
+	}{
+		{"include list", &config.Config{IncludeExt: []string{".go"}}, []string{"a.go"}},
+		{"excluded extension", &config.Config{ExcludeExt: []string{".json", ".toml"}}, []string{"go.mod", "a.go"}},
+		{"excluded name", &config.Config{ExcludeExt: []string{"go.mod"}}, []string{"Cargo.toml", "web/package.json", "a.go"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.MaxFileSize = 1024
+			tc.cfg.PriorityFiles = config.DefaultPriorityFiles()
+
+			files, err := NewFileGathererFromMapFS(tc.cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+			if err != nil {
+				t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+			}
+
+			assertFilePathsMatch(t, files, tc.expected)
+		})
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"pkg/gatherer_test.go", true},
+		{"pkg/gatherer.go", false},
+		{"pkg/testdata.go", false},
+		{"tests/test_parser.py", true},
+		{"app/parser_test.py", true},
+		{"tests/conftest.py", true},
+		{"app/parser.py", false},
+		{"app/testing.py", false},
+		{"src/app.spec.ts", true},
+		{"src/app.test.ts", true},
+		{"src/Button.test.tsx", true},
+		{"src/__tests__/app.ts", true},
+		{"src/app.ts", false},
+		{"src/latest.ts", false},
+		{"spec/models/user_spec.rb", true},
+		{"test/user_test.rb", true},
+		{"test/test_user.rb", true},
+		{"app/models/user.rb", false},
+		{"src/main/java/UserServiceTest.java", true},
+		{"src/main/java/Manifest.java", false},
+		{"tests/integration.rs", true},
+		{"src/lib.rs", false},
+		{"docs/test_plan.md", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := IsTestFile(tc.path, LanguageFromPath(tc.path)); got != tc.expected {
+				t.Errorf("IsTestFile(%q) = %v, expected %v", tc.path, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFileGatherer_TestFilters(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":            "package main\n",
+		"main_test.go":       "package main\n",
+		"app/models.py":      "x = 1\n",
+		"app/test_models.py": "def test_x(): pass\n",
+		"web/app.ts":         "export {}\n",
+		"web/app.spec.ts":    "it('works', () => {})\n",
+		"lib/user.rb":        "class User; end\n",
+		"spec/user_spec.rb":  "describe User do; end\n",
+	})
+
+	cfg := config.NewConfig()
+	cfg.ExcludeTests = true
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"app/models.py", "lib/user.rb", "main.go", "web/app.ts"})
+
+	cfg = config.NewConfig()
+	cfg.TestsOnly = true
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"app/test_models.py", "main_test.go", "spec/user_spec.rb", "web/app.spec.ts"})
+}
+
+func TestFileGatherer_PathsRemovedDuringWalk(t *testing.T) {
+	fsys := vanishingFS{
+		MapFS: newMapFS(map[string]string{
+			"main.go":         "package main\n",
+			"deleted.go":      "package main\n",
+			"gone/helper.go":  "package gone\n",
+			"kept/handler.go": "package kept\n",
+		}),
+		removed: map[string]bool{"deleted.go": true, "gone": true},
+	}
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go"}}
+
+	files, err := NewFileGatherer(cfg, testRoot, zap.New(core), withFS(fsys)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"kept/handler.go", "main.go"})
+
+	if logs.Len() != 0 {
+		t.Errorf("Expected removed paths to be skipped without warnings, got %v", logs.All())
+	}
+}
+
+func TestFileGatherer_SortByLanguage(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"a/main.go":    "package main\n",
+		"a/script.py":  "print(1)\n",
+		"b/lib.go":     "package b\n",
+		"b/util.py":    "x = 1\n",
+		"c/README.md":  "# c\n",
+		"z.go":         "package z\n",
+		"go.mod":       "module example\n",
+		"c/handler.go": "package c\n",
+	})
+
+	cfg := config.NewConfig()
+	cfg.Sort = config.SortLanguage
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	if files[0].Path != "go.mod" {
+		t.Errorf("Expected the priority file go.mod first, got %q", files[0].Path)
+	}
+
+	var goFiles, allFiles []string
+
+	first, last := -1, -1
+
+	for i, file := range files {
+		allFiles = append(allFiles, file.Path)
+
+		if file.Language != "go" {
+			continue
+		}
+
+		if first < 0 {
+			first = i
+		}
+
+		last = i
+		goFiles = append(goFiles, file.Path)
+	}
+
+	if last-first+1 != len(goFiles) {
+		t.Errorf("Expected the .go files to be contiguous, got order %v", allFiles)
+	}
+
+	expected := []string{"a/main.go", "b/lib.go", "c/handler.go", "z.go"}
+	if !slices.Equal(goFiles, expected) {
+		t.Errorf("Expected .go files in path order %v, got %v", expected, goFiles)
+	}
+}
+
+func TestFileGatherer_MaxFiles(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"a.go":     "package a\n",
+		"b.go":     "package b\n",
+		"c/c.go":   "package c\n",
+		"d/d.go":   "package d\n",
+		"e/e/e.go": "package e\n",
+	})
+
+	cfg := &config.Config{MaxFileSize: 1024 * 1024, IncludeExt: []string{".go"}, MaxFiles: 3}
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.New(core)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error at the file limit: %v", err)
+	}
+
+	assertFilePathsMatch(t, files, []string{"a.go", "b.go", "c/c.go"})
+
+	if logs.FilterMessage("Stopped walking at the file limit").Len() != 1 {
+		t.Errorf("Expected the walk to report stopping at the limit, got %v", logs.All())
+	}
+
+	cfg.MaxFiles = 5
+	core, logs = observer.New(zapcore.InfoLevel)
+
+	files, err = NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.New(core)).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	if len(files) != 5 {
+		t.Errorf("Expected all 5 files when the limit equals the file count, got %d", len(files))
+	}
+
+	if logs.FilterMessage("Stopped walking at the file limit").Len() != 0 {
+		t.Error("Expected no report of stopping at the limit when no file was left out")
+	}
+}
+
+func TestFileGatherer_NoDefaultExcludeDirs(t *testing.T) {
+	fsys := newMapFS(map[string]string{
+		"main.go":            "package main\n",
+		"vendor/lib/a.go":    "package lib\n",
+		"dist/bundle.js":     "console.log(1)\n",
+		"generated/types.go": "package generated\n",
+	})
+
+	cfg := config.NewConfig()
+	cfg.NoDefaultExcludeDirs = true
+	cfg.ExcludeDirs = []string{"generated"}
+
+	files, err := NewFileGathererFromMapFS(cfg, fsys, testRoot, zap.NewNop()).GatherFiles(context.Background())
+	if err != nil {
+		t.Fatalf("GatherFiles() returned an unexpected error: %v", err)
+	}
+
+	// The built-in list is cleared, but user-provided exclusions still apply.
+	assertFilePathsMatch(t, files, []string{"dist/bundle.js", "main.go", "vendor/lib/a.go"})
+}
+
+func TestIsBinary_KnownMagic(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"WebAssembly", "\x00asm\x01", true},
+		{"Java class", "\xCA\xFE\xBA\xBE short class file", true},
+		{"Short PNG", "\x89PNG\r\n\x1a\nIHDR mostly printable", true},
+		{"JPEG", "\xFF\xD8\xFF\xE0 JFIF", true},
+		{"ZIP", "PK\x03\x04 archive", true},
+		{"Text starting with PK", "PKGBUILD for the package\n", false},
+		{"Plain text", "package main\n", false},
+		{"Empty", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isBinary([]byte(tc.data)); got != tc.expected {
+				t.Errorf("isBinary(%q) = %v, expected %v", tc.data, got, tc.expected)
+			}
+		})
+	}
+}
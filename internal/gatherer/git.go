@@ -0,0 +1,102 @@
+package gatherer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownRef is returned when a git reference cannot be resolved.
+var ErrUnknownRef = errors.New("unknown git reference")
+
+// ErrNoDefaultBranch is returned when the default branch of a repository cannot be determined.
+var ErrNoDefaultBranch = errors.New("cannot determine the default branch")
+
+// runGit runs a git command in dir and returns its trimmed standard output.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	out, err := gitOutput(ctx, dir, args...)
+
+	return strings.TrimSpace(out), err
+}
+
+// gitOutput runs a git command in dir and returns its standard output as is.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), nil
+}
+
+// changedFilesSince returns the set of paths, relative to dir, changed between ref and HEAD.
+// With mergeBase, changes are taken from the merge base of ref and HEAD instead, so that
+// commits made on ref after HEAD branched off are not included.
+func changedFilesSince(ctx context.Context, dir, ref string, mergeBase bool) (map[string]bool, error) {
+	if _, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRef, ref)
+	}
+
+	revRange := ref + "..HEAD"
+	if mergeBase {
+		revRange = ref + "...HEAD"
+	}
+
+	// With -z, paths are NUL-terminated and not quoted, even with unusual characters.
+	out, err := gitOutput(ctx, dir, "diff", "--name-only", "-z", "--relative", revRange)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+
+	for _, name := range strings.Split(out, "\x00") {
+		if name != "" {
+			changed[filepath.FromSlash(name)] = true
+		}
+	}
+
+	return changed, nil
+}
+
+// DefaultBranch returns the default branch of the git repository containing dir: the
+// branch origin/HEAD points to, or else a local main or master branch.
+func DefaultBranch(ctx context.Context, dir string) (string, error) {
+	if ref, err := runGit(ctx, dir, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return ref, nil
+	}
+
+	for _, branch := range []string{"main", "master"} {
+		if _, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+			return branch, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no origin/HEAD, main or master in %s", ErrNoDefaultBranch, dir)
+}
+
+// RepositoryName infers a repository's name from the URL of its origin remote,
+// falling back to the base name of dir when there is no such remote.
+func RepositoryName(ctx context.Context, dir string) string {
+	if url, err := runGit(ctx, dir, "remote", "get-url", "origin"); err == nil && url != "" {
+		name := strings.TrimSuffix(strings.TrimRight(url, "/"), ".git")
+		if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		if name != "" {
+			return name
+		}
+	}
+
+	return filepath.Base(dir)
+}
@@ -2,37 +2,162 @@ package gatherer
 
 import (
 	"bufio"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/gobwas/glob"
 )
 
 // GitignoreParser handles parsing and matching gitignore patterns.
 type GitignoreParser struct {
-	patterns []glob.Glob
+	rules    []ignoreRule
+	sources  []IgnoreSource
 	basePath string
+	tree     rootedFS // Where ignore files inside the base directory are read from.
+}
+
+// IgnoreSource records the original patterns loaded from one ignore file.
+type IgnoreSource struct {
+	Path     string // Relative to the base directory when inside it, absolute otherwise.
+	Patterns []string
+}
+
+// ignoreRule is a compiled pattern scoped to the directory of the ignore file it came from.
+type ignoreRule struct {
+	dir     string
+	pattern glob.Glob
+	match   IgnoreMatch
+	dirOnly bool // Set for the part of a trailing-slash pattern that matches the directory itself.
+}
+
+// IgnoreMatch attributes an ignored path to the ignore file and pattern that caught it.
+type IgnoreMatch struct {
+	Source  string // Path of the ignore file, as in IgnoreSource.
+	Pattern string // The pattern as written in the ignore file.
 }
 
 // NewGitignoreParser creates a new parser for the given directory.
 func NewGitignoreParser(basePath string) *GitignoreParser {
 	return &GitignoreParser{
 		basePath: basePath,
+		tree:     rootedFS{root: basePath},
 	}
 }
 
 // LoadGitignore loads and translates patterns from a .gitignore file.
-func (gp *GitignoreParser) LoadGitignore() (err error) {
-	gitignorePath := filepath.Join(gp.basePath, ".gitignore")
+// It reports whether the file exists.
+func (gp *GitignoreParser) LoadGitignore() (bool, error) {
+	err := gp.loadPatterns(filepath.Join(gp.basePath, ".gitignore"), gp.basePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
 
-	file, openErr := os.Open(gitignorePath)
-	if openErr != nil {
-		if os.IsNotExist(openErr) {
-			return nil // No .gitignore file is not an error.
+	return true, err
+}
+
+// LoadIgnoreFile loads gitignore-syntax patterns from the named file in the base directory.
+// A missing file is not an error.
+func (gp *GitignoreParser) LoadIgnoreFile(name string) error {
+	err := gp.loadPatterns(filepath.Join(gp.basePath, name), gp.basePath)
+	if os.IsNotExist(err) {
+		return nil // No ignore file is not an error.
+	}
+
+	return err
+}
+
+// LoadIgnoreFileAt loads gitignore-syntax patterns from the file at path, which may live
+// outside the base directory. Its patterns are matched relative to the base directory.
+// Unlike LoadIgnoreFile, a missing file is an error.
+func (gp *GitignoreParser) LoadIgnoreFileAt(path string) error {
+	return gp.loadPatterns(path, gp.basePath)
+}
+
+// IncludeFileName is the allowlist file that, when present in the root directory,
+// restricts gathering to the paths matching its gitignore-syntax patterns.
+const IncludeFileName = ".code2mdinclude"
+
+// LoadIncludeFile loads the patterns of the IncludeFileName allowlist in the base directory.
+// It reports whether the file exists.
+func (gp *GitignoreParser) LoadIncludeFile() (bool, error) {
+	err := gp.loadPatterns(filepath.Join(gp.basePath, IncludeFileName), gp.basePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return true, err
+}
+
+// LoadParentGitignores loads the .gitignore files of the directories between the base
+// directory and the root of its git repository, so that scanning a subdirectory still
+// honors ignore rules defined higher up. Nothing is loaded outside a git repository.
+// It reports whether any parent .gitignore was found.
+func (gp *GitignoreParser) LoadParentGitignores() (bool, error) {
+	repoRoot, ok := findRepoRoot(gp.basePath)
+	if !ok || repoRoot == gp.basePath {
+		return false, nil
+	}
+
+	found := false
+
+	for dir := filepath.Dir(gp.basePath); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".gitignore")); err == nil {
+			found = true
+
+			if err := gp.loadPatterns(filepath.Join(dir, ".gitignore"), dir); err != nil {
+				return found, err
+			}
+		}
+
+		if dir == repoRoot {
+			return found, nil
 		}
+	}
+}
+
+// LoadGitInfoExclude loads the repository-local ignore patterns in .git/info/exclude of
+// the git repository containing the base directory. Its patterns are relative to the
+// repository root. Nothing is loaded outside a git repository or when the file is missing.
+func (gp *GitignoreParser) LoadGitInfoExclude() error {
+	repoRoot, ok := findRepoRoot(gp.basePath)
+	if !ok {
+		return nil
+	}
 
-		return openErr
+	err := gp.loadPatterns(filepath.Join(repoRoot, ".git", "info", "exclude"), repoRoot)
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return nil // No exclude file, or .git is a file as in worktrees and submodules.
+	}
+
+	return err
+}
+
+// findRepoRoot returns the nearest directory at or above dir that contains a .git entry.
+func findRepoRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
+
+// loadPatterns loads gitignore-syntax patterns from the file at ignorePath,
+// scoping them to paths below dir.
+func (gp *GitignoreParser) loadPatterns(ignorePath, dir string) (err error) {
+	file, err := gp.open(ignorePath)
+	if err != nil {
+		return err
 	}
 
 	defer func() {
@@ -42,6 +167,12 @@ func (gp *GitignoreParser) LoadGitignore() (err error) {
 		}
 	}()
 
+	source := IgnoreSource{Path: gp.displayPath(ignorePath)}
+
+	defer func() {
+		gp.sources = append(gp.sources, source)
+	}()
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -50,12 +181,19 @@ func (gp *GitignoreParser) LoadGitignore() (err error) {
 			continue
 		}
 
+		source.Patterns = append(source.Patterns, line)
+
 		// A single gitignore pattern can result in multiple glob patterns.
 		patternsToCompile := translateGitignoreToGlobs(line)
-		for _, p := range patternsToCompile {
+		for i, p := range patternsToCompile {
 			// We must compile with the separator to handle `**` correctly.
 			if g, compileErr := glob.Compile(p, '/'); compileErr == nil {
-				gp.patterns = append(gp.patterns, g)
+				gp.rules = append(gp.rules, ignoreRule{
+					dir:     dir,
+					pattern: g,
+					match:   IgnoreMatch{Source: source.Path, Pattern: line},
+					dirOnly: i == 0 && strings.HasSuffix(line, "/"),
+				})
 			}
 		}
 	}
@@ -63,7 +201,33 @@ func (gp *GitignoreParser) LoadGitignore() (err error) {
 	return scanner.Err()
 }
 
+// open opens an ignore file. Files inside the base directory are read from the parser's
+// tree, and files outside it, such as parent .gitignores, from the operating system.
+func (gp *GitignoreParser) open(path string) (fs.File, error) {
+	if _, inside := gp.tree.name(path); inside {
+		return gp.tree.open(path)
+	}
+
+	return os.Open(path)
+}
+
+// Sources returns the loaded ignore files with their patterns, in load order.
+func (gp *GitignoreParser) Sources() []IgnoreSource {
+	return gp.sources
+}
+
+// displayPath returns path relative to the base directory when it lies inside it.
+func (gp *GitignoreParser) displayPath(path string) string {
+	rel, err := filepath.Rel(gp.basePath, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return filepath.ToSlash(rel)
+}
+
 // translateGitignoreToGlobs converts a single .gitignore pattern into one or more glob patterns.
+// The first glob matches the path itself and the second everything below it.
 func translateGitignoreToGlobs(line string) []string {
 	// A pattern ending with "/" signifies that it should only match directories.
 	isDirPattern := strings.HasSuffix(line, "/")
@@ -87,18 +251,55 @@ func translateGitignoreToGlobs(line string) []string {
 
 // ShouldIgnore checks if a file path should be ignored based on gitignore patterns.
 func (gp *GitignoreParser) ShouldIgnore(filePath string) bool {
-	relPath, err := filepath.Rel(gp.basePath, filePath)
-	if err != nil || relPath == "." {
-		return false
+	return gp.Matches(filePath)
+}
+
+// ShouldIgnoreDir checks if a directory should be ignored based on gitignore patterns.
+// Unlike files, directories also match directory-only patterns such as "node_modules/",
+// so that the walk can skip the whole tree instead of checking every file below it.
+func (gp *GitignoreParser) ShouldIgnoreDir(dirPath string) bool {
+	_, ok := gp.MatchDir(dirPath)
+	return ok
+}
+
+// Matches reports whether the file path matches any loaded pattern.
+func (gp *GitignoreParser) Matches(filePath string) bool {
+	_, ok := gp.Match(filePath)
+	return ok
+}
+
+// Match returns the first loaded pattern matching the file path, in load order.
+// Each pattern is matched against the path relative to the directory of its ignore file.
+func (gp *GitignoreParser) Match(filePath string) (IgnoreMatch, bool) {
+	return gp.match(filePath, false)
+}
+
+// MatchDir is like Match for a directory, which directory-only patterns also match.
+func (gp *GitignoreParser) MatchDir(dirPath string) (IgnoreMatch, bool) {
+	return gp.match(dirPath, true)
+}
+
+// match returns the first loaded pattern matching the path.
+func (gp *GitignoreParser) match(filePath string, isDir bool) (IgnoreMatch, bool) {
+	if filePath == gp.basePath {
+		return IgnoreMatch{}, false
 	}
-	// Use the system's native separator for matching, as the glob was compiled with it.
-	relPath = filepath.ToSlash(relPath)
 
-	for _, g := range gp.patterns {
-		if g.Match(relPath) {
-			return true
+	for _, rule := range gp.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		relPath, err := filepath.Rel(rule.dir, filePath)
+		if err != nil || relPath == "." {
+			continue
+		}
+
+		// Patterns are compiled with '/' as the separator.
+		if rule.pattern.Match(filepath.ToSlash(relPath)) {
+			return rule.match, true
 		}
 	}
 
-	return false
+	return IgnoreMatch{}, false
 }
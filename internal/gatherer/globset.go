@@ -0,0 +1,55 @@
+package gatherer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/gobwas/glob"
+)
+
+// GlobSet is a compiled list of user-supplied glob patterns.
+type GlobSet []glob.Glob
+
+// CompileGlobSet compiles the given patterns using '/' as the path separator.
+func CompileGlobSet(patterns []string) (GlobSet, error) {
+	set := make(GlobSet, 0, len(patterns))
+
+	for _, p := range patterns {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", p, err)
+		}
+
+		set = append(set, g)
+	}
+
+	return set, nil
+}
+
+// Match reports whether the relative path, or its base name, matches any pattern in the set.
+func (gs GlobSet) Match(path string) bool {
+	slashPath := filepath.ToSlash(path)
+	base := filepath.Base(path)
+
+	for _, g := range gs {
+		if g.Match(slashPath) || g.Match(base) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchPath reports whether the full relative path matches any pattern in the set.
+// Unlike Match, it does not fall back to the base name.
+func (gs GlobSet) MatchPath(path string) bool {
+	slashPath := filepath.ToSlash(path)
+
+	for _, g := range gs {
+		if g.Match(slashPath) {
+			return true
+		}
+	}
+
+	return false
+}
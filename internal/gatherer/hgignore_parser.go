@@ -0,0 +1,195 @@
+package gatherer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// ErrUnknownHgignoreSyntax is returned when a .hgignore selects a syntax other than glob or regexp.
+var ErrUnknownHgignoreSyntax = errors.New("unknown .hgignore syntax")
+
+// HgignoreFileName is the name of Mercurial's ignore file at the repository root.
+const HgignoreFileName = ".hgignore"
+
+// Mercurial pattern syntaxes. Regexp is the default until a "syntax:" line says otherwise.
+const (
+	hgSyntaxGlob     = "glob"
+	hgSyntaxRegexp   = "regexp"
+	hgSyntaxRootGlob = "rootglob"
+)
+
+// hgSyntax returns the syntax selected by a name used after "syntax:" or as a pattern prefix.
+func hgSyntax(name string) (string, bool) {
+	switch name {
+	case "glob", "relglob":
+		return hgSyntaxGlob, true
+	case "rootglob":
+		return hgSyntaxRootGlob, true
+	case "re", "regexp", "relre":
+		return hgSyntaxRegexp, true
+	default:
+		return "", false
+	}
+}
+
+// HgignorePattern is a compiled .hgignore pattern.
+type HgignorePattern struct {
+	Pattern string // The pattern as written in the file, including any syntax prefix.
+	matcher glob.Glob
+}
+
+// HgignoreParser parses Mercurial .hgignore files. Patterns are regular expressions by
+// default; "syntax: glob" and "syntax: regexp" lines switch the syntax of the lines that
+// follow, and a "glob:" or "re:" prefix sets it for a single pattern.
+type HgignoreParser struct {
+	syntax string
+}
+
+// NewHgignoreParser creates a new parser starting in Mercurial's default regexp syntax.
+func NewHgignoreParser() *HgignoreParser {
+	return &HgignoreParser{syntax: hgSyntaxRegexp}
+}
+
+// Parse reads .hgignore patterns from r. Patterns that do not compile are skipped,
+// like invalid gitignore patterns.
+func (hp *HgignoreParser) Parse(r io.Reader) ([]HgignorePattern, error) {
+	var patterns []HgignorePattern
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripHgComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "syntax:"); ok {
+			syntax, known := hgSyntax(strings.TrimSpace(name))
+			if !known {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownHgignoreSyntax, strings.TrimSpace(name))
+			}
+
+			hp.syntax = syntax
+
+			continue
+		}
+
+		if matcher, err := hp.compile(line); err == nil {
+			patterns = append(patterns, HgignorePattern{Pattern: line, matcher: matcher})
+		}
+	}
+
+	return patterns, scanner.Err()
+}
+
+// compile translates one pattern, honoring a per-line syntax prefix.
+func (hp *HgignoreParser) compile(line string) (glob.Glob, error) {
+	syntax, pattern := hp.syntax, line
+	if name, rest, found := strings.Cut(line, ":"); found {
+		if prefixed, known := hgSyntax(name); known {
+			syntax, pattern = prefixed, rest
+		}
+	}
+
+	switch syntax {
+	case hgSyntaxRegexp:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return regexpMatcher{re}, nil
+	case hgSyntaxRootGlob:
+		// A root glob matches from the repository root, and everything below a match.
+		return glob.Compile(pattern+"{,/**}", '/')
+	default:
+		// A glob matches in any directory, and everything below a match.
+		return glob.Compile("{,**/}"+pattern+"{,/**}", '/')
+	}
+}
+
+// stripHgComment removes a "#" comment from an .hgignore line; "\#" is a literal "#".
+func stripHgComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '#':
+			return strings.ReplaceAll(line[:i], `\#`, "#")
+		}
+	}
+
+	return strings.ReplaceAll(line, `\#`, "#")
+}
+
+// regexpMatcher adapts a regular expression to the glob.Glob interface used by ignore rules.
+// Like Mercurial, it matches anywhere in the path unless the expression is anchored.
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+// Match reports whether the expression matches the slash-separated path.
+func (m regexpMatcher) Match(path string) bool {
+	return m.re.MatchString(path)
+}
+
+// isHgRepo reports whether the base directory is the root of a Mercurial repository
+// that is not also a git repository.
+func (gp *GitignoreParser) isHgRepo() bool {
+	if info, err := gp.tree.stat(filepath.Join(gp.basePath, ".hg")); err != nil || !info.IsDir() {
+		return false
+	}
+
+	_, err := gp.tree.stat(filepath.Join(gp.basePath, ".git"))
+
+	return os.IsNotExist(err)
+}
+
+// LoadHgignore loads the .hgignore of the base directory with Mercurial's pattern syntax.
+// It reports whether the file was found; a missing file is not an error.
+func (gp *GitignoreParser) LoadHgignore() (found bool, err error) {
+	path := filepath.Join(gp.basePath, HgignoreFileName)
+
+	file, err := gp.open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	patterns, err := NewHgignoreParser().Parse(file)
+	if err != nil {
+		return true, err
+	}
+
+	source := IgnoreSource{Path: gp.displayPath(path)}
+
+	for _, p := range patterns {
+		source.Patterns = append(source.Patterns, p.Pattern)
+		gp.rules = append(gp.rules, ignoreRule{
+			dir:     gp.basePath,
+			pattern: p.matcher,
+			match:   IgnoreMatch{Source: source.Path, Pattern: p.Pattern},
+		})
+	}
+
+	gp.sources = append(gp.sources, source)
+
+	return true, nil
+}
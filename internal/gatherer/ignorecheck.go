@@ -0,0 +1,67 @@
+package gatherer
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+)
+
+// IgnoredPath is a path excluded by an ignore file. Ignored directories are reported
+// once, with a trailing slash, rather than file by file.
+type IgnoredPath struct {
+	Path string // Relative to the root directory, with forward slashes.
+	IgnoreMatch
+}
+
+// IgnoredPaths walks the tree and reports every path excluded by the loaded ignore files,
+// with the ignore file and pattern responsible. Only ignore files are consulted; the
+// other filters of the gatherer are not applied.
+func (fg *FileGatherer) IgnoredPaths(ctx context.Context) ([]IgnoredPath, error) {
+	var ignored []IgnoredPath
+
+	err := fg.tree.walkDir(func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		match, ok := fg.gitignoreParser.Match(path)
+		if d.IsDir() {
+			match, ok = fg.gitignoreParser.MatchDir(path)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		rel, err := filepath.Rel(fg.rootPath, path)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			rel += "/"
+		}
+
+		ignored = append(ignored, IgnoredPath{Path: rel, IgnoreMatch: match})
+
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ignored, nil
+}
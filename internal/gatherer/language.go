@@ -0,0 +1,51 @@
+package gatherer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// LanguageFromPath returns the code fence language for a file based on its name and extension.
+func LanguageFromPath(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	fileName := strings.ToLower(filepath.Base(path))
+	langMap := map[string]string{
+		".go": "go",
+		// -----------------------
+		".py": "python", ".js": "javascript", ".ts": "typescript",
+		".jsx": "jsx", ".tsx": "tsx", ".java": "java", ".c": "c", ".cpp": "cpp",
+		".cc": "cpp", ".cxx": "cpp", ".h": "c", ".hpp": "cpp", ".cs": "csharp",
+		".php": "php", ".rb": "ruby", ".rs": "rust", ".swift": "swift", ".kt": "kotlin",
+		".scala": "scala", ".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish",
+		".sql": "sql", ".html": "html", ".htm": "html", ".css": "css", ".scss": "scss",
+		".sass": "sass", ".less": "less", ".vue": "vue", ".yaml": "yaml", ".yml": "yaml",
+		".json": "json", ".xml": "xml", ".toml": "toml", ".ini": "ini", ".cfg": "ini",
+		".conf": "ini", ".md": "markdown", ".txt": "text", ".rst": "rst", ".dockerfile": "dockerfile",
+		".lua": "lua", ".pl": "perl", ".pm": "perl", ".cob": "cobol", ".cbl": "cobol",
+		".f90": "fortran", ".f95": "fortran", ".for": "fortran", ".adb": "ada", ".ads": "ada",
+		".env": "dotenv", ".bicep": "bicep", ".bicepparam": "bicep", ".groovy": "groovy",
+		".smk": "python", ".snakemake": "python", ".nf": "groovy",
+		".proto": "protobuf", ".graphql": "graphql", ".gql": "graphql", ".thrift": "thrift",
+		".avsc": "json", ".prisma": "prisma",
+	}
+
+	specialFiles := map[string]string{
+		"dockerfile": "dockerfile", "makefile": "makefile", "jenkinsfile": "groovy",
+		"snakefile": "python", "nextflow.config": "groovy",
+	}
+
+	// Dotenv variants such as .env.example have the variant as their extension.
+	if fileName == ".env" || strings.HasPrefix(fileName, ".env.") {
+		return "dotenv"
+	}
+
+	if lang, exists := specialFiles[fileName]; exists {
+		return lang
+	}
+
+	if lang, exists := langMap[ext]; exists {
+		return lang
+	}
+
+	return "text"
+}
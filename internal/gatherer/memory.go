@@ -0,0 +1,55 @@
+package gatherer
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// heapObjectsMetric is the runtime metric reporting bytes occupied by live and unswept heap objects.
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// memoryPollInterval is how often a paused worker re-checks heap usage.
+const memoryPollInterval = 10 * time.Millisecond
+
+// memoryGate pauses file reads while heap usage is above a soft limit. It always
+// lets one read proceed, so the pipeline keeps making progress even when retained
+// content alone exceeds the limit.
+type memoryGate struct {
+	limit    uint64
+	inflight atomic.Int64
+}
+
+// acquire blocks until a read may start. Every successful acquire must be paired with release.
+func (mg *memoryGate) acquire(ctx context.Context) error {
+	for {
+		if heapBytes() < mg.limit {
+			mg.inflight.Add(1)
+			return nil
+		}
+
+		if mg.inflight.CompareAndSwap(0, 1) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memoryPollInterval):
+		}
+	}
+}
+
+// release marks a read as finished.
+func (mg *memoryGate) release() {
+	mg.inflight.Add(-1)
+}
+
+// heapBytes returns the current heap usage as reported by the runtime.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+
+	return sample[0].Value.Uint64()
+}
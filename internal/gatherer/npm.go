@@ -0,0 +1,47 @@
+package gatherer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoNpmFiles is returned when --npm-only is used but package.json has no "files" field.
+var ErrNoNpmFiles = errors.New(`package.json has no "files" field`)
+
+// loadNpmFilesWhitelist reads the "files" array from package.json at the root of tree and
+// compiles it into a GlobSet. Each entry matches itself and, if it is a directory,
+// everything beneath it. package.json itself is always included, as npm does.
+func loadNpmFilesWhitelist(tree rootedFS) (GlobSet, error) {
+	data, err := tree.readFile(filepath.Join(tree.root, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Files []string `json:"files"`
+	}
+
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	if len(pkg.Files) == 0 {
+		return nil, ErrNoNpmFiles
+	}
+
+	patterns := []string{"package.json"}
+
+	for _, entry := range pkg.Files {
+		entry = strings.TrimSuffix(strings.TrimPrefix(entry, "./"), "/")
+		if entry == "" {
+			continue
+		}
+
+		patterns = append(patterns, entry, entry+"/**")
+	}
+
+	return CompileGlobSet(patterns)
+}
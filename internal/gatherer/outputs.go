@@ -0,0 +1,27 @@
+package gatherer
+
+import "path/filepath"
+
+// WithExcludedOutputs keeps the files and directories matching the given absolute
+// filepath.Match patterns out of the gathered files. Callers pass the outputs a run
+// writes, and patterns for those that earlier runs may have left behind.
+func WithExcludedOutputs(patterns ...string) GathererOption {
+	return func(fg *FileGatherer) {
+		fg.outputPatterns = append(fg.outputPatterns, patterns...)
+	}
+}
+
+// isOutput reports whether the absolute path is an output of this or an earlier run.
+func (fg *FileGatherer) isOutput(path string) bool {
+	if path == fg.outputPath {
+		return true
+	}
+
+	for _, pattern := range fg.outputPatterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+
+	return false
+}
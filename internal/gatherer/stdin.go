@@ -0,0 +1,34 @@
+package gatherer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StdinPath is the path reported for content read from standard input.
+const StdinPath = "<stdin>"
+
+// ReadStdinFile reads all of r as a single virtual file named StdinPath.
+// lang is either an extension such as "go" or ".py", which is mapped as if the
+// file were named stdin.<lang>, or a language name used for the fence as is.
+func ReadStdinFile(r io.Reader, lang string) (FileInfo, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	ext := strings.TrimPrefix(lang, ".")
+
+	language := LanguageFromPath("stdin." + ext)
+	if language == "text" && ext != "txt" {
+		language = strings.ToLower(ext)
+	}
+
+	return FileInfo{
+		Path:       StdinPath,
+		Size:       int64(len(content)),
+		RawContent: content,
+		Language:   language,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package gatherer
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IsTestFile reports whether the file at relPath, detected as language by LanguageFromPath,
+// is a test file by the naming conventions of the language's common test frameworks:
+// _test.go for Go, test_*.py and *_test.py for Python, *.test.ts and *.spec.ts (or files
+// under __tests__) for JavaScript and TypeScript, *_spec.rb and *_test.rb for Ruby,
+// *Test.java and similar for the JVM and .NET languages, and tests/ for Rust.
+func IsTestFile(relPath, language string) bool {
+	slashPath := filepath.ToSlash(relPath)
+	base := path.Base(slashPath)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+
+	switch language {
+	case "go":
+		return strings.HasSuffix(base, "_test.go")
+	case "python":
+		return strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test") || base == "conftest.py"
+	case "javascript", "typescript", "jsx", "tsx":
+		return strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") || inDirectory(slashPath, "__tests__")
+	case "ruby":
+		return strings.HasSuffix(stem, "_spec") || strings.HasSuffix(stem, "_test") || strings.HasPrefix(stem, "test_")
+	case "java", "kotlin", "scala", "groovy", "csharp", "php", "swift":
+		return strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests") || strings.HasSuffix(stem, "Spec")
+	case "rust":
+		// Unit tests live inline; integration tests live in a crate's tests directory.
+		return inDirectory(slashPath, "tests")
+	default:
+		return false
+	}
+}
+
+// inDirectory reports whether the slash-separated path is below a directory named dir.
+func inDirectory(slashPath, dir string) bool {
+	return strings.HasPrefix(slashPath, dir+"/") || strings.Contains(slashPath, "/"+dir+"/")
+}
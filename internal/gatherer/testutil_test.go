@@ -0,0 +1,68 @@
+package gatherer
+
+import (
+	"code2md/internal/config"
+	"io/fs"
+	"testing/fstest"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testRoot is the root path that in-memory test trees are presented at.
+const testRoot = "/repo"
+
+// NewFileGathererFromMapFS creates a FileGatherer over the in-memory fsys, as if fsys
+// were checked out at rootPath. The tree and the ignore files inside it are read from
+// fsys only, so tests need no temporary directories. It is test-only:
+//
+//	func NewFileGathererFromMapFS(cfg *config.Config, fsys fstest.MapFS, rootPath string, logger *zap.Logger) *FileGatherer
+func NewFileGathererFromMapFS(cfg *config.Config, fsys fstest.MapFS, rootPath string, logger *zap.Logger) *FileGatherer {
+	return NewFileGatherer(cfg, rootPath, logger, withFS(fsys))
+}
+
+// newMapFS builds an in-memory tree from a map of slash-separated paths to contents.
+func newMapFS(files map[string]string) fstest.MapFS {
+	modTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	fsys := make(fstest.MapFS, len(files))
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content), ModTime: modTime}
+	}
+
+	return fsys
+}
+
+// vanishingFS is an in-memory tree whose removed paths are still listed by the walk and
+// still stat, but can no longer be opened, read or listed, as if they were deleted mid-walk.
+type vanishingFS struct {
+	fstest.MapFS
+	removed map[string]bool
+}
+
+// Open returns fs.ErrNotExist for removed paths.
+func (v vanishingFS) Open(name string) (fs.File, error) {
+	if v.removed[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return v.MapFS.Open(name)
+}
+
+// ReadDir returns fs.ErrNotExist for removed directories.
+func (v vanishingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if v.removed[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return v.MapFS.ReadDir(name)
+}
+
+// ReadFile returns fs.ErrNotExist for removed files.
+func (v vanishingFS) ReadFile(name string) ([]byte, error) {
+	if v.removed[name] {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return v.MapFS.ReadFile(name)
+}
@@ -0,0 +1,37 @@
+package gatherer
+
+import "unicode"
+
+// wordRunesPerToken is the rough number of letters or digits per LLM token within a word.
+const wordRunesPerToken = 4
+
+// EstimateContentTokens approximates the number of LLM tokens in content from its shape
+// rather than its size: runs of letters and digits count one token per four characters,
+// every other visible character counts as a token of its own, and whitespace is free.
+// Dense, symbol-heavy content therefore estimates higher than sparse, indented content
+// of the same size.
+func EstimateContentTokens(content []byte) int {
+	tokens, word := 0, 0
+
+	endWord := func() {
+		tokens += (word + wordRunesPerToken - 1) / wordRunesPerToken
+		word = 0
+	}
+
+	for _, r := range string(content) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+
+			tokens++
+		}
+	}
+
+	endWord()
+
+	return tokens
+}
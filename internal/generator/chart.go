@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"bufio"
+	"code2md/internal/gatherer"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// chartWidth is the bar length of a language holding all the bytes.
+	chartWidth = 40
+	// chartLanguages is the number of languages shown before the rest are summed as "other".
+	chartLanguages = 5
+	// sizeBarWidth is the length of the relative size bar of the largest file.
+	sizeBarWidth = 20
+)
+
+// languageShare is the number of bytes of one language in the gathered files.
+type languageShare struct {
+	language string
+	bytes    int64
+}
+
+// languageShares aggregates file sizes by language, largest first, ties broken by name.
+func languageShares(files []gatherer.FileInfo) []languageShare {
+	totals := make(map[string]int64)
+	for _, file := range files {
+		totals[languageOf(file)] += file.Size
+	}
+
+	shares := make([]languageShare, 0, len(totals))
+	for language, bytes := range totals {
+		shares = append(shares, languageShare{language: language, bytes: bytes})
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].bytes != shares[j].bytes {
+			return shares[i].bytes > shares[j].bytes
+		}
+
+		return shares[i].language < shares[j].language
+	})
+
+	return shares
+}
+
+// writeLanguageChart writes an ASCII bar chart of the top languages by byte share.
+func writeLanguageChart(writer *bufio.Writer, files []gatherer.FileInfo) error {
+	shares := languageShares(files)
+	if len(shares) > chartLanguages {
+		other := languageShare{language: "other"}
+		for _, share := range shares[chartLanguages:] {
+			other.bytes += share.bytes
+		}
+
+		shares = append(shares[:chartLanguages], other)
+	}
+
+	total := calculateTotalSize(files)
+	if total == 0 {
+		return nil
+	}
+
+	labelWidth := 0
+	for _, share := range shares {
+		labelWidth = max(labelWidth, len(share.language))
+	}
+
+	if _, err := fmt.Fprintf(writer, "```text\n"); err != nil {
+		return err
+	}
+
+	for _, share := range shares {
+		fraction := float64(share.bytes) / float64(total)
+		bar := strings.Repeat("#", int(math.Round(fraction*chartWidth)))
+
+		if _, err := fmt.Fprintf(writer, "%-*s %-*s %5.1f%%\n",
+			labelWidth, share.language, chartWidth, bar, fraction*100); err != nil { //nolint:mnd // Percent.
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "```\n\n")
+
+	return err
+}
+
+// maxFileSize returns the size of the largest file.
+func maxFileSize(files []gatherer.FileInfo) int64 {
+	var largest int64
+	for _, file := range files {
+		largest = max(largest, file.Size)
+	}
+
+	return largest
+}
+
+// sizeBar renders size relative to largest as a fixed-width ASCII bar, e.g. "#####...............".
+// Any non-empty file gets at least one "#", so that small files stay distinguishable from empty ones.
+func sizeBar(size, largest int64) string {
+	filled := 0
+	if largest > 0 && size > 0 {
+		filled = max(1, int(math.Round(float64(size)/float64(largest)*sizeBarWidth)))
+	}
+
+	return strings.Repeat("#", filled) + strings.Repeat(".", sizeBarWidth-filled)
+}
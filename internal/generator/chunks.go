@@ -0,0 +1,268 @@
+package generator
+
+import (
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrInvalidChunkTokens is returned when the llm-chunks format is used without a positive chunk size.
+var ErrInvalidChunkTokens = errors.New("chunk tokens must be positive")
+
+// bytesPerToken is the rough number of bytes per LLM token used for estimates.
+const bytesPerToken = 4
+
+// ChunkDocument is the top-level structure written by ChunkGenerator.
+type ChunkDocument struct {
+	Name        string              `json:"name,omitempty"`
+	Repository  string              `json:"repository"`
+	Generated   time.Time           `json:"generated"`
+	ChunkTokens int                 `json:"chunk_tokens"`
+	ChunkCount  int                 `json:"chunk_count"`
+	Manifest    map[string][]string `json:"manifest"`
+	Chunks      []Chunk             `json:"chunks"`
+}
+
+// Chunk is a self-describing piece of the codebase of roughly ChunkTokens tokens.
+type Chunk struct {
+	ID      string      `json:"id"`
+	Tokens  int         `json:"tokens"`
+	Files   []ChunkFile `json:"files"`
+	Content string      `json:"content"`
+}
+
+// ChunkFile records which lines of a file a chunk contains.
+type ChunkFile struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	// Omitted reports that the file matches NoContentFor; its lines are then both zero.
+	Omitted bool `json:"omitted,omitempty"`
+}
+
+// ChunkGenerator is responsible for creating the llm-chunks file.
+type ChunkGenerator struct {
+	config *config.Config
+}
+
+// NewChunkGenerator creates a new ChunkGenerator.
+func NewChunkGenerator(cfg *config.Config) *ChunkGenerator {
+	return &ChunkGenerator{config: cfg}
+}
+
+// GenerateChunks packs the gathered files into numbered chunks and writes them with a manifest.
+func (cg *ChunkGenerator) GenerateChunks(files []gatherer.FileInfo, rootPath string) error {
+	if cg.config.ChunkTokens <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidChunkTokens, cg.config.ChunkTokens)
+	}
+
+	return WriteFile(cg, cg.config.OutputFile, files, rootPath)
+}
+
+// Generate writes the chunk document for the gathered files to w.
+func (cg *ChunkGenerator) Generate(w io.Writer, files []gatherer.FileInfo, rootPath string) error {
+	if cg.config.ChunkTokens <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidChunkTokens, cg.config.ChunkTokens)
+	}
+
+	rules, err := newContentRules(cg.config)
+	if err != nil {
+		return err
+	}
+
+	chunks := packChunks(files, cg.config.ChunkTokens, rules)
+
+	doc := ChunkDocument{
+		Name:        cg.config.RepoName,
+		Repository:  rootPath,
+		Generated:   time.Now(),
+		ChunkTokens: cg.config.ChunkTokens,
+		ChunkCount:  len(chunks),
+		Manifest:    make(map[string][]string, len(chunks)),
+		Chunks:      chunks,
+	}
+
+	for _, chunk := range chunks {
+		paths := make([]string, len(chunk.Files))
+		for i, file := range chunk.Files {
+			paths[i] = file.Path
+		}
+
+		doc.Manifest[chunk.ID] = paths
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return phaseError("chunk document", encoder.Encode(doc))
+}
+
+// estimateTokens approximates the number of LLM tokens in s.
+func estimateTokens(s string) int {
+	return int(EstimateTokens(int64(len(s))))
+}
+
+// EstimateTokens approximates the number of LLM tokens in size bytes of text.
+func EstimateTokens(size int64) int64 {
+	return (size + bytesPerToken - 1) / bytesPerToken
+}
+
+// packChunks splits the files into chunks of at most maxTokens estimated tokens.
+// Files are split at line boundaries when they do not fit; a single line longer
+// than maxTokens is kept intact in its own chunk. Files that the rules omit are listed
+// without their content.
+func packChunks(files []gatherer.FileInfo, maxTokens int, rules contentRules) []Chunk {
+	cb := &chunkBuilder{maxTokens: maxTokens}
+
+	for _, file := range files {
+		if rules.mode(file.Path) == contentOmitted {
+			cb.addOmittedFile(file.Path)
+			continue
+		}
+
+		cb.addFile(file.Path, languageOf(file), file.Text())
+	}
+
+	cb.flush()
+
+	return cb.chunks
+}
+
+// chunkBuilder accumulates file sections into the chunk currently being filled.
+type chunkBuilder struct {
+	maxTokens int
+	chunks    []Chunk
+	content   strings.Builder
+	tokens    int
+	files     []ChunkFile
+}
+
+// chunkID returns the stable ID of the nth chunk, counting from one.
+func chunkID(n int) string {
+	return fmt.Sprintf("chunk-%04d", n)
+}
+
+// addFile appends a file to the chunks, starting a new chunk whenever the current one is full.
+func (cb *chunkBuilder) addFile(path, lang, content string) {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// Reserve room for the section header and fences with the longest continuation note.
+	n := len(lines)
+	overhead := estimateTokens(sectionHeader(path, lang, n, n, n, len(cb.chunks)+1, len(cb.chunks)+n+1) + "```\n\n")
+
+	// Prefer moving a file that fits in a fresh chunk over splitting it.
+	if cb.tokens > 0 && cb.tokens+overhead+estimateTokens(content) > cb.maxTokens &&
+		overhead+estimateTokens(content) <= cb.maxTokens {
+		cb.flush()
+	}
+
+	for start := 0; start < len(lines); {
+		budget := cb.maxTokens - cb.tokens - overhead
+		end, used := start, 0
+
+		for end < len(lines) {
+			lineTokens := estimateTokens(lines[end])
+			if used+lineTokens > budget && (end > start || cb.tokens > 0) {
+				break
+			}
+
+			used += lineTokens
+			end++
+		}
+
+		if end == start {
+			cb.flush()
+			continue
+		}
+
+		cb.writeSection(path, lang, lines[start:end], start+1, len(lines))
+
+		start = end
+		if start < len(lines) {
+			cb.flush()
+		}
+	}
+}
+
+// addOmittedFile appends a section that lists a file without its content.
+func (cb *chunkBuilder) addOmittedFile(path string) {
+	section := fmt.Sprintf("### %s\n\n_(content omitted)_\n\n", path)
+
+	if cb.tokens > 0 && cb.tokens+estimateTokens(section) > cb.maxTokens {
+		cb.flush()
+	}
+
+	cb.content.WriteString(section)
+	cb.tokens += estimateTokens(section)
+	cb.files = append(cb.files, ChunkFile{Path: path, Omitted: true})
+}
+
+// sectionHeader renders the heading and opening fence of a file section covering lines first..last.
+// prevChunk and nextChunk name the chunks holding the rest of a split file; zero means none.
+func sectionHeader(path, lang string, first, last, total, prevChunk, nextChunk int) string {
+	heading := fmt.Sprintf("### %s (lines %d-%d of %d", path, first, last, total)
+
+	if prevChunk > 0 {
+		heading += "; continued from " + chunkID(prevChunk)
+	}
+
+	if nextChunk > 0 {
+		heading += "; continues in " + chunkID(nextChunk)
+	}
+
+	return heading + ")\n\n```" + lang + "\n"
+}
+
+// writeSection appends the given lines of a file, starting at line first, to the current chunk.
+func (cb *chunkBuilder) writeSection(path, lang string, lines []string, first, total int) {
+	last := first + len(lines) - 1
+	current := len(cb.chunks) + 1
+
+	var prevChunk, nextChunk int
+	if first > 1 {
+		prevChunk = current - 1
+	}
+
+	if last < total {
+		nextChunk = current + 1
+	}
+
+	body := strings.Join(lines, "")
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+
+	section := sectionHeader(path, lang, first, last, total, prevChunk, nextChunk) + body + "```\n\n"
+
+	cb.content.WriteString(section)
+	cb.tokens += estimateTokens(section)
+	cb.files = append(cb.files, ChunkFile{Path: path, StartLine: first, EndLine: last})
+}
+
+// flush closes the current chunk if it has any content.
+func (cb *chunkBuilder) flush() {
+	if len(cb.files) == 0 {
+		return
+	}
+
+	content := cb.content.String()
+
+	cb.chunks = append(cb.chunks, Chunk{
+		ID:      chunkID(len(cb.chunks) + 1),
+		Tokens:  estimateTokens(content),
+		Files:   cb.files,
+		Content: content,
+	})
+
+	cb.content.Reset()
+	cb.tokens = 0
+	cb.files = nil
+}
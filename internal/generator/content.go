@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"code2md/internal/gatherer"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// isProseLanguage reports whether the language is prose rather than code.
+func isProseLanguage(lang string) bool {
+	switch lang {
+	case "markdown", "text", "rst":
+		return true
+	default:
+		return false
+	}
+}
+
+// wrapProse hard-wraps lines longer than width at word boundaries.
+// Lines inside fenced code blocks (``` or ~~~) are left untouched.
+func wrapProse(content string, width int) string {
+	if width <= 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	wrapped := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+
+		if inFence || len(line) <= width {
+			wrapped = append(wrapped, line)
+			continue
+		}
+
+		wrapped = append(wrapped, wrapLine(line, width)...)
+	}
+
+	return strings.Join(wrapped, "\n")
+}
+
+// wrapLine greedily splits a single line into lines of at most width columns,
+// keeping the original indentation on continuation lines. Words longer than
+// width are kept intact on their own line.
+func wrapLine(line string, width int) []string {
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	words := strings.Fields(line)
+
+	var (
+		result  []string
+		current strings.Builder
+	)
+
+	current.WriteString(indent)
+
+	for _, word := range words {
+		if current.Len() > len(indent) && current.Len()+1+len(word) > width {
+			result = append(result, current.String())
+			current.Reset()
+			current.WriteString(indent)
+		}
+
+		if current.Len() > len(indent) {
+			current.WriteByte(' ')
+		}
+
+		current.WriteString(word)
+	}
+
+	return append(result, current.String())
+}
+
+// trimTrailingWhitespace removes trailing spaces and tabs from every line,
+// leaving line breaks (including a final newline) untouched.
+func trimTrailingWhitespace(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// collapseBlankLines replaces each run of consecutive blank lines with a single blank line.
+// Lines holding only spaces and tabs count as blank; the first line of a run is kept as is.
+func collapseBlankLines(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		// The element after a final newline is not a line of its own.
+		if i == len(lines)-1 && line == "" {
+			kept = append(kept, line)
+			break
+		}
+
+		blank := strings.TrimSpace(line) == ""
+		if blank && i > 0 && strings.TrimSpace(lines[i-1]) == "" {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return strings.Join(kept, "\n")
+}
+
+// GrepFiles reduces each file's content to the lines matching the Grep pattern, each
+// prefixed with its line number. Files without a matching line are dropped. It applies
+// to every output, so it runs before any generator.
+func GrepFiles(files []gatherer.FileInfo, pattern string) ([]gatherer.FileInfo, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --grep pattern: %w", err)
+	}
+
+	return grepFiles(files, re), nil
+}
+
+// grepFiles reduces each file's content to the lines matching re, each prefixed with its
+// line number. Files without a matching line are dropped.
+func grepFiles(files []gatherer.FileInfo, re *regexp.Regexp) []gatherer.FileInfo {
+	matched := make([]gatherer.FileInfo, 0, len(files))
+
+	for _, file := range files {
+		var content strings.Builder
+
+		for i, line := range strings.Split(strings.TrimSuffix(file.Text(), "\n"), "\n") {
+			if re.MatchString(line) {
+				fmt.Fprintf(&content, "%d: %s\n", i+1, line)
+			}
+		}
+
+		if content.Len() == 0 {
+			continue
+		}
+
+		file.Content = content.String()
+		matched = append(matched, file)
+	}
+
+	return matched
+}
@@ -0,0 +1,33 @@
+package generator
+
+import "fmt"
+
+// GenerateError records the output file and the phase of generation that failed.
+type GenerateError struct {
+	Phase string // Part of the output being written, such as "header" or "file contents".
+	Path  string // Output file, when known.
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *GenerateError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("writing %s: %v", e.Phase, e.Err)
+	}
+
+	return fmt.Sprintf("writing %s of %s: %v", e.Phase, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// phaseError wraps a non-nil err as a GenerateError of the given phase.
+func phaseError(phase string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &GenerateError{Phase: phase, Err: err}
+}
@@ -4,61 +4,355 @@ import (
 	"bufio"
 	"code2md/internal/config"
 	"code2md/internal/gatherer"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 )
 
+// ErrOutputIsDirectory is returned when the output path refers to a directory instead of a file.
+var ErrOutputIsDirectory = errors.New("output path is a directory")
+
+// ErrPathOutsideOutputDir is returned when a file's path, made relative to --relative-to,
+// would place its markdown file outside the output directory.
+var ErrPathOutsideOutputDir = errors.New("file path leaves the output directory")
+
+// ErrUnknownEncoding is returned when the configured output encoding is not supported.
+var ErrUnknownEncoding = errors.New("unknown output encoding")
+
+// utf8BOM is the UTF-8 byte order mark expected by some Windows tools.
+const utf8BOM = "\xEF\xBB\xBF"
+
+// Generator renders gathered files into one output format.
+type Generator interface {
+	Generate(w io.Writer, files []gatherer.FileInfo, rootPath string) error
+}
+
+// WriteFile writes the generator's output to the file at path. The output is written to
+// a temporary file in the same directory, which replaces path only when generation
+// succeeds, so a failed run leaves an existing output untouched.
+func WriteFile(gen Generator, path string, files []gatherer.FileInfo, rootPath string) (err error) {
+	if err := validateOutputPath(path); err != nil {
+		return err
+	}
+
+	mode := outputFileMode(path)
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return &GenerateError{Phase: "output", Path: path, Err: err}
+	}
+
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err := gen.Generate(f, files, rootPath); err != nil {
+		// Name the output file in errors raised while writing it.
+		var genErr *GenerateError
+		if errors.As(err, &genErr) && genErr.Path == "" {
+			genErr.Path = path
+		}
+
+		return err
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		return &GenerateError{Phase: "output", Path: path, Err: err}
+	}
+
+	if err := f.Close(); err != nil {
+		return &GenerateError{Phase: "output", Path: path, Err: err}
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return &GenerateError{Phase: "output", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// outputFileMode returns the permissions of the existing file at path, so that replacing
+// it keeps them, or the permissions os.Create gives new files under the usual umask.
+func outputFileMode(path string) os.FileMode {
+	if info, err := os.Stat(path); err == nil {
+		return info.Mode().Perm()
+	}
+
+	return 0o644 //nolint:mnd // rw-r--r--, as os.Create with a 022 umask.
+}
+
 // MarkdownGenerator is responsible for creating the markdown file.
 type MarkdownGenerator struct {
-	config *config.Config
+	config        *config.Config
+	ignoreSources []gatherer.IgnoreSource // Listed in an appendix when ShowIgnoreRules is set.
+	transformers  []ContentTransformer
+	part          *outputPart // Set while writing one part of a split output.
+}
+
+// ContentTransformer rewrites the content of a file before it is written to the output.
+type ContentTransformer func(path, language, content string) string
+
+// GeneratorOption customizes a MarkdownGenerator created by NewMarkdownGenerator.
+type GeneratorOption func(*MarkdownGenerator)
+
+// WithTransformer registers a content transformation, applied after the built-in ones in registration order.
+func WithTransformer(fn ContentTransformer) GeneratorOption {
+	return func(mg *MarkdownGenerator) {
+		mg.transformers = append(mg.transformers, fn)
+	}
 }
 
 // NewMarkdownGenerator creates a new MarkdownGenerator.
-func NewMarkdownGenerator(cfg *config.Config) *MarkdownGenerator {
-	return &MarkdownGenerator{config: cfg}
+func NewMarkdownGenerator(cfg *config.Config, opts ...GeneratorOption) *MarkdownGenerator {
+	mg := &MarkdownGenerator{config: cfg}
+	for _, opt := range opts {
+		opt(mg)
+	}
+
+	return mg
+}
+
+// WithIgnoreSources sets the ignore files listed in the appendix written with ShowIgnoreRules.
+func (mg *MarkdownGenerator) WithIgnoreSources(sources []gatherer.IgnoreSource) *MarkdownGenerator {
+	mg.ignoreSources = sources
+	return mg
 }
 
 // GenerateMarkdown creates the final markdown file from the gathered file info.
 func (mg *MarkdownGenerator) GenerateMarkdown(files []gatherer.FileInfo, rootPath string) error {
-	f, err := os.Create(mg.config.OutputFile)
+	return WriteFile(mg, mg.config.OutputFile, files, rootPath)
+}
+
+// Generate writes the markdown document for the gathered files to w.
+func (mg *MarkdownGenerator) Generate(w io.Writer, files []gatherer.FileInfo, rootPath string) error {
+	rules, err := newContentRules(mg.config)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
 
-	defer func() {
-		if closeErr := f.Close(); closeErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", closeErr)
+	if err := validateGroupBy(mg.groupBy()); err != nil {
+		return err
+	}
+
+	writeBOM, err := WritesBOM(mg.config)
+	if err != nil {
+		return err
+	}
+
+	seedPrompt, err := resolveSeedPrompt(mg.config.SeedPrompt)
+	if err != nil {
+		return err
+	}
+
+	prefix, err := resolveContentText(mg.config.ContentPrefix, mg.config.ContentPrefixFile, "content-prefix")
+	if err != nil {
+		return err
+	}
+
+	suffix, err := resolveContentText(mg.config.ContentSuffix, mg.config.ContentSuffixFile, "content-suffix")
+	if err != nil {
+		return err
+	}
+
+	var tmpl *template.Template
+	if mg.config.Template != "" {
+		if tmpl, err = parseTemplate(mg.config.Template); err != nil {
+			return err
 		}
-	}()
+	}
 
-	writer := bufio.NewWriter(f)
+	writer := bufio.NewWriter(w)
+
+	if writeBOM {
+		if _, err := writer.WriteString(utf8BOM); err != nil {
+			return phaseError("byte order mark", err)
+		}
+	}
+
+	for _, text := range []string{seedPrompt, prefix} {
+		if text == "" {
+			continue
+		}
+
+		if _, err := fmt.Fprintf(writer, "%s\n\n", text); err != nil {
+			return phaseError("prefix", err)
+		}
+	}
+
+	// A split output is sampled once as a whole, so its parts keep the sampled files.
+	var omitted map[string]int
+	if mg.part != nil {
+		omitted = mg.part.omitted
+	} else {
+		files, omitted = mg.sampleFiles(files)
+	}
+
+	files = mg.orderFiles(files)
+
+	if tmpl != nil {
+		err = phaseError("template", mg.renderTemplate(writer, tmpl, files, rootPath))
+	} else {
+		err = mg.writeDocument(writer, files, omitted, rules, rootPath)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if suffix != "" {
+		if _, err := fmt.Fprintf(writer, "%s\n", suffix); err != nil {
+			return phaseError("suffix", err)
+		}
+	}
+
+	return phaseError("output", writer.Flush())
+}
+
+// writeDocument writes the built-in layout: header, table of contents, file sections,
+// and the optional ignore rules appendix.
+func (mg *MarkdownGenerator) writeDocument(
+	writer *bufio.Writer, files []gatherer.FileInfo, omitted map[string]int, rules contentRules, rootPath string,
+) error {
+	if !mg.config.NoHeader {
+		if err := writeHeader(writer, files, mg.config.RepoName, rootPath); err != nil {
+			return phaseError("header", err)
+		}
+	}
+
+	if mg.part != nil {
+		if err := writePartNavigation(writer, mg.part); err != nil {
+			return phaseError("part navigation", err)
+		}
+	}
+
+	if mg.config.Chart {
+		if err := writeLanguageChart(writer, files); err != nil {
+			return phaseError("language chart", err)
+		}
+	}
+
+	// Without a table of contents nothing links to the sections, so they get no anchors.
+	var anchors []string
+
+	if !mg.config.NoTOC {
+		anchors = mg.fileAnchors(files)
+
+		if err := mg.writeTableOfContents(writer, files, anchors); err != nil {
+			return phaseError("table of contents", err)
+		}
+	}
+
+	if err := mg.writeFileContents(writer, files, anchors, omitted, rules); err != nil {
+		return phaseError("file contents", err)
+	}
+
+	if mg.config.ShowIgnoreRules {
+		return phaseError("ignore rules", writeIgnoreRules(writer, mg.ignoreSources))
+	}
+
+	return nil
+}
+
+// GenerateDirectory writes each gathered file to its own markdown file under dir,
+// named after the file's relative path with ".md" appended. Directories are created as needed.
+// Paths that would leave dir, such as ../lib/a.go, are rejected before anything is written.
+func (mg *MarkdownGenerator) GenerateDirectory(files []gatherer.FileInfo, dir string) error {
+	rules, err := newContentRules(mg.config)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return fmt.Errorf("%w: %s", ErrPathOutsideOutputDir, file.Path)
+		}
+	}
+
+	for _, file := range files {
+		if err := mg.writeFileToDirectory(dir, file, rules.mode(file.Path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileToDirectory writes a single file section to its own markdown file under dir.
+func (mg *MarkdownGenerator) writeFileToDirectory(dir string, file gatherer.FileInfo, mode contentMode) (err error) {
+	outPath := filepath.Join(dir, file.Path+".md")
+	if err := os.MkdirAll(filepath.Dir(outPath), 0750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
 
 	defer func() {
-		if flushErr := writer.Flush(); flushErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to flush buffer: %v\n", flushErr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
 		}
 	}()
 
-	if err := writeHeader(writer, files, rootPath); err != nil {
+	writer := bufio.NewWriter(f)
+	if err := mg.writeFileSection(writer, file, mode, 0); err != nil {
 		return err
 	}
 
-	if err := writeTableOfContents(writer, files); err != nil {
-		return err
+	return writer.Flush()
+}
+
+// WritesBOM resolves the output encoding and BOM settings into whether a BOM is written.
+// BOM is a shorthand for EncodingUTF8BOM, so it wins over EncodingUTF8, the default.
+func WritesBOM(cfg *config.Config) (bool, error) {
+	switch cfg.OutputEncoding {
+	case "", config.EncodingUTF8:
+		return cfg.BOM, nil
+	case config.EncodingUTF8BOM:
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: %q (expected %s or %s)",
+			ErrUnknownEncoding, cfg.OutputEncoding, config.EncodingUTF8, config.EncodingUTF8BOM)
+	}
+}
+
+// validateOutputPath rejects output paths that name an existing directory or end in a separator.
+func validateOutputPath(path string) error {
+	isDir := strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator))
+	if !isDir {
+		info, err := os.Stat(path)
+		isDir = err == nil && info.IsDir()
+	}
+
+	if isDir {
+		return fmt.Errorf("%w: %q; specify a file name instead, e.g. %q",
+			ErrOutputIsDirectory, path, filepath.Join(path, "codebase.md"))
 	}
 
-	return writeFileContents(writer, files)
+	return nil
 }
 
-func writeHeader(writer *bufio.Writer, files []gatherer.FileInfo, rootPath string) error {
+func writeHeader(writer *bufio.Writer, files []gatherer.FileInfo, repoName, rootPath string) error {
 	if _, err := fmt.Fprintf(writer, "# Codebase Analysis\n\n"); err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(writer, "**Repository:** %s  \n", rootPath); err != nil {
+	repository := rootPath
+	if repoName != "" {
+		repository = fmt.Sprintf("%s (`%s`)", repoName, rootPath)
+	}
+
+	if _, err := fmt.Fprintf(writer, "**Repository:** %s  \n", repository); err != nil {
 		return err
 	}
 
@@ -71,7 +365,7 @@ func writeHeader(writer *bufio.Writer, files []gatherer.FileInfo, rootPath strin
 	}
 
 	totalSize := calculateTotalSize(files)
-	if _, err := fmt.Fprintf(writer, "**Total Size:** %s  \n\n", formatBytes(totalSize)); err != nil {
+	if _, err := fmt.Fprintf(writer, "**Total Size:** %s  \n\n", FormatBytes(totalSize)); err != nil {
 		return err
 	}
 
@@ -87,13 +381,53 @@ func calculateTotalSize(files []gatherer.FileInfo) int64 {
 	return totalSize
 }
 
-func writeTableOfContents(writer *bufio.Writer, files []gatherer.FileInfo) error {
+func (mg *MarkdownGenerator) writeTableOfContents(
+	writer *bufio.Writer, files []gatherer.FileInfo, anchors []string,
+) error {
 	if _, err := fmt.Fprintf(writer, "## Table of Contents\n\n"); err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if _, err := fmt.Fprintf(writer, "- [%s](#%s)\n", file.Path, sanitizeAnchor(file.Path)); err != nil {
+	if mg.config.TOCTable {
+		if err := writeTOCTable(writer, files, anchors); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintf(writer, "\n")
+
+		return err
+	}
+
+	if mg.config.TOCHierarchy {
+		byPath := make(map[string]string, len(files))
+		for i, file := range files {
+			byPath[file.Path] = anchors[i]
+		}
+
+		if err := writeTOCTree(writer, buildTOCTree(files), byPath, 0, ""); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintf(writer, "\n")
+
+		return err
+	}
+
+	// Grouped files are listed under an entry for their group, mirroring the section headings.
+	indent := ""
+
+	for i, file := range files {
+		if group := mg.groupOf(file); group != "" {
+			indent = "  "
+
+			if i == 0 || group != mg.groupOf(files[i-1]) {
+				if _, err := fmt.Fprintf(writer, "- `%s/`\n", group); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := fmt.Fprintf(writer, "%s- [%s](#%s)\n", indent, file.Path, anchors[i]); err != nil {
 			return err
 		}
 	}
@@ -105,43 +439,120 @@ func writeTableOfContents(writer *bufio.Writer, files []gatherer.FileInfo) error
 	return nil
 }
 
-func writeFileContents(writer *bufio.Writer, files []gatherer.FileInfo) error {
-	if _, err := fmt.Fprintf(writer, "## File Contents\n\n"); err != nil {
-		return err
+func (mg *MarkdownGenerator) writeFileContents(
+	writer *bufio.Writer, files []gatherer.FileInfo, anchors []string, omitted map[string]int, rules contentRules,
+) error {
+	// Omission notes follow the last listed file of their directory.
+	lastInDir := make(map[string]int, len(omitted))
+	for i, file := range files {
+		lastInDir[path.Dir(file.Path)] = i
 	}
 
-	for _, file := range files {
-		if err := writeFileSection(writer, file); err != nil {
+	// Size bars are relative to the largest file, which needs a pass over all files first.
+	var largest int64
+	if mg.config.RelativeSizeBar {
+		largest = maxFileSize(files)
+	}
+
+	if mg.groupBy() == "" {
+		if _, err := fmt.Fprintf(writer, "## File Contents\n\n"); err != nil {
 			return err
 		}
 	}
 
+	for i, file := range files {
+		if group := mg.groupOf(file); group != "" && (i == 0 || group != mg.groupOf(files[i-1])) {
+			if _, err := fmt.Fprintf(writer, "## `%s/`\n\n", group); err != nil {
+				return err
+			}
+		}
+
+		// An explicit anchor keeps TOC links working regardless of how the renderer slugs headings.
+		if anchors != nil {
+			if _, err := fmt.Fprintf(writer, "<a id=\"%s\"></a>\n\n", anchors[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := mg.writeFileSection(writer, file, rules.mode(file.Path), largest); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+
+		if dir := path.Dir(file.Path); omitted[dir] > 0 && lastInDir[dir] == i {
+			if err := writeOmissionNote(writer, dir, omitted[dir]); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-func writeFileSection(writer *bufio.Writer, file gatherer.FileInfo) error {
+// writeFileSection writes the heading, metadata, and content of one file. A positive
+// largest adds a bar showing the file's size relative to the largest file of the document.
+func (mg *MarkdownGenerator) writeFileSection(
+	writer *bufio.Writer, file gatherer.FileInfo, mode contentMode, largest int64,
+) error {
 	if _, err := fmt.Fprintf(writer, "### %s\n\n", file.Path); err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(writer, "**Size:** %s  \n", formatBytes(file.Size)); err != nil {
+	if _, err := fmt.Fprintf(writer, "**Size:** %s  \n", FormatBytes(file.Size)); err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(writer, "**Path:** `%s`  \n\n", file.Path); err != nil {
+	if largest > 0 {
+		if _, err := fmt.Fprintf(writer, "**Relative Size:** `%s`  \n", sizeBar(file.Size, largest)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(writer, "**Path:** `%s`  \n", file.Path); err != nil {
 		return err
 	}
 
-	lang := getLanguageFromPath(file.Path)
+	if len(file.SameAs) > 0 {
+		if _, err := fmt.Fprintf(writer, "**Same file as:** `%s`  \n", strings.Join(file.SameAs, "`, `")); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(writer, "\n"); err != nil {
+		return err
+	}
+
+	if mode == contentOmitted {
+		_, err := fmt.Fprintf(writer, "_(content omitted)_\n\n")
+		return err
+	}
+
+	lang := languageOf(file)
+
+	if mg.config.AnnotateImports {
+		if imports := fileImports(file.Path, file.Text(), lang); len(imports) > 0 {
+			if _, err := fmt.Fprintf(writer, "<!-- imports: %s -->\n", strings.Join(imports, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+
 	if _, err := fmt.Fprintf(writer, "```%s\n", lang); err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(writer, "%s", file.Content); err != nil {
+	text := file.Text()
+	if mode == contentSummary {
+		text = summarizeContent(file.Path, text, lang)
+	}
+
+	content := mg.prepareContent(file.Path, text, lang)
+	if _, err := fmt.Fprintf(writer, "%s", content); err != nil {
 		return err
 	}
 
-	if !strings.HasSuffix(file.Content, "\n") {
+	// The closing fence needs its own line, so a missing final newline is always added.
+	noFinalNewline := !strings.HasSuffix(content, "\n")
+	if noFinalNewline {
 		if _, err := fmt.Fprintf(writer, "\n"); err != nil {
 			return err
 		}
@@ -151,36 +562,117 @@ func writeFileSection(writer *bufio.Writer, file gatherer.FileInfo) error {
 		return err
 	}
 
+	if noFinalNewline && content != "" && mg.config.PreserveNoFinalNewline {
+		if _, err := fmt.Fprintf(writer, "%s\n\n", noFinalNewlineNote); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// noFinalNewlineNote marks a file whose content does not end with a newline.
+const noFinalNewlineNote = "_(no newline at end of file)_"
+
+// writeIgnoreRules writes an appendix listing each ignore file and the patterns loaded from it.
+func writeIgnoreRules(writer *bufio.Writer, sources []gatherer.IgnoreSource) error {
+	if _, err := fmt.Fprintf(writer, "## Appendix: Ignore Rules\n\n"); err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		_, err := fmt.Fprintf(writer, "_No ignore files were loaded._\n")
+		return err
+	}
+
+	for _, source := range sources {
+		if _, err := fmt.Fprintf(writer, "### `%s`\n\n", source.Path); err != nil {
+			return err
+		}
+
+		if len(source.Patterns) == 0 {
+			if _, err := fmt.Fprintf(writer, "_(no patterns)_\n\n"); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, pattern := range source.Patterns {
+			if _, err := fmt.Fprintf(writer, "- `%s`\n", pattern); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(writer, "\n"); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func getLanguageFromPath(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	fileName := strings.ToLower(filepath.Base(path))
-	langMap := map[string]string{
-		".go": "go",
-		// -----------------------
-		".py": "python", ".js": "javascript", ".ts": "typescript",
-		".jsx": "jsx", ".tsx": "tsx", ".java": "java", ".c": "c", ".cpp": "cpp",
-		".cc": "cpp", ".cxx": "cpp", ".h": "c", ".hpp": "cpp", ".cs": "csharp",
-		".php": "php", ".rb": "ruby", ".rs": "rust", ".swift": "swift", ".kt": "kotlin",
-		".scala": "scala", ".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish",
-		".sql": "sql", ".html": "html", ".htm": "html", ".css": "css", ".scss": "scss",
-		".sass": "sass", ".less": "less", ".vue": "vue", ".yaml": "yaml", ".yml": "yaml",
-		".json": "json", ".xml": "xml", ".toml": "toml", ".ini": "ini", ".cfg": "ini",
-		".conf": "ini", ".md": "markdown", ".txt": "text", ".rst": "rst",
-		".dockerfile": "dockerfile",
+// prepareContent applies the configured content transformations for the given language,
+// followed by the registered ContentTransformers.
+func (mg *MarkdownGenerator) prepareContent(path, content, lang string) string {
+	if mg.config.TrimTrailingWhitespace {
+		content = trimTrailingWhitespace(content)
+	}
+
+	if mg.config.StripBlankLines {
+		content = collapseBlankLines(content)
+	}
+
+	if mg.config.WrapWidth > 0 && isProseLanguage(lang) {
+		content = wrapProse(content, mg.config.WrapWidth)
 	}
 
-	if fileName == "dockerfile" || fileName == "makefile" {
-		return strings.ToLower(fileName)
+	for _, transform := range mg.transformers {
+		content = transform(path, lang, content)
 	}
 
-	if lang, exists := langMap[ext]; exists {
-		return lang
+	return content
+}
+
+// languageOf returns the file's detected language, falling back to path-based detection.
+func languageOf(file gatherer.FileInfo) string {
+	if file.Language != "" {
+		return file.Language
+	}
+
+	return gatherer.LanguageFromPath(file.Path)
+}
+
+// fileAnchors returns the anchor linking the table of contents to each file section.
+// With RelativeAnchorIDs, anchors are short and unique by position (file-1, file-2, ...).
+// Otherwise they are derived from the path, and paths that sanitize to the same anchor
+// get -1, -2, ... suffixes in order, as GitHub does for duplicate headings.
+func (mg *MarkdownGenerator) fileAnchors(files []gatherer.FileInfo) []string {
+	anchors := make([]string, len(files))
+
+	if mg.config.RelativeAnchorIDs {
+		for i := range files {
+			anchors[i] = fmt.Sprintf("file-%d", i+1)
+		}
+
+		return anchors
+	}
+
+	used := make(map[string]bool, len(files))
+
+	for i, file := range files {
+		base := sanitizeAnchor(file.Path)
+		anchor := base
+
+		for n := 1; used[anchor]; n++ {
+			anchor = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		used[anchor] = true
+		anchors[i] = anchor
 	}
 
-	return "text"
+	return anchors
 }
 
 func sanitizeAnchor(text string) string {
@@ -194,7 +686,8 @@ func sanitizeAnchor(text string) string {
 	return result
 }
 
-func formatBytes(bytes int64) string {
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 KB".
+func FormatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
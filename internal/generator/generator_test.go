@@ -1,9 +1,40 @@
 package generator
 
 import (
+	"bytes"
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 )
 
+// generateToString runs GenerateMarkdown into a temp file and returns its content.
+func generateToString(t *testing.T, cfg *config.Config, files []gatherer.FileInfo) string {
+	t.Helper()
+
+	if cfg.OutputFile == "" {
+		cfg.OutputFile = filepath.Join(t.TempDir(), "out.md")
+	}
+
+	if err := NewMarkdownGenerator(cfg).GenerateMarkdown(files, "/repo"); err != nil {
+		t.Fatalf("GenerateMarkdown() returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	return string(data)
+}
+
 func TestFormatBytes(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -15,9 +46,9 @@ func TestFormatBytes(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual := formatBytes(tc.bytes)
+			actual := FormatBytes(tc.bytes)
 			if actual != tc.expected {
-				t.Errorf("formatBytes(%d): expected %q, got %q", tc.bytes, tc.expected, actual)
+				t.Errorf("FormatBytes(%d): expected %q, got %q", tc.bytes, tc.expected, actual)
 			}
 		})
 	}
@@ -42,21 +73,1230 @@ func TestSanitizeAnchor(t *testing.T) {
 	}
 }
 
-func TestGetLanguageFromPath(t *testing.T) {
+func TestGenerateMarkdown_NoContentFor(t *testing.T) {
+	cfg := &config.Config{NoContentFor: []string{"secrets/*"}}
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 12, Content: "package main\n"},
+		{Path: "secrets/key.txt", Size: 11, Content: "TOP-SECRET\n"},
+	}
+
+	output := generateToString(t, cfg, files)
+
+	if !strings.Contains(output, "package main") {
+		t.Error("Expected unmatched file to keep its full content")
+	}
+
+	if strings.Contains(output, "TOP-SECRET") {
+		t.Error("Expected matched file content to be omitted")
+	}
+
+	if !strings.Contains(output, "[secrets/key.txt](#secrets-key-txt)") {
+		t.Error("Expected matched file to remain in the table of contents")
+	}
+
+	section := output[strings.Index(output, "### secrets/key.txt"):]
+	if !strings.Contains(section, "(content omitted)") {
+		t.Errorf("Expected matched file section to contain the omitted note, got:\n%s", section)
+	}
+}
+
+func TestGenerateMarkdown_NoTOCAndNoHeader(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 13, Content: "package main\n"}}
+
+	testCases := []struct {
+		name       string
+		cfg        config.Config
+		wantHeader bool
+		wantTOC    bool
+	}{
+		{"Default", config.Config{}, true, true},
+		{"TOC only", config.Config{NoHeader: true}, false, true},
+		{"Header only", config.Config{NoTOC: true}, true, false},
+		{"Content only", config.Config{NoHeader: true, NoTOC: true}, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := generateToString(t, &tc.cfg, files)
+
+			if strings.Contains(output, "# Codebase Analysis") != tc.wantHeader {
+				t.Errorf("Expected header present=%v, got:\n%s", tc.wantHeader, output)
+			}
+
+			if strings.Contains(output, "## Table of Contents") != tc.wantTOC {
+				t.Errorf("Expected TOC present=%v, got:\n%s", tc.wantTOC, output)
+			}
+
+			if strings.Contains(output, "<a id=") != tc.wantTOC {
+				t.Errorf("Expected section anchors only with a TOC, got:\n%s", output)
+			}
+
+			if !strings.Contains(output, "```go\npackage main\n```") {
+				t.Errorf("Expected file content in every mode, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestGenerateMarkdown_SameAsNote(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a.go", Size: 10, Content: "package a\n", SameAs: []string{"b.go"}},
+		{Path: "b.go", Size: 10, Content: "package a\n", RealPath: "a.go", SameAs: []string{"a.go"}},
+	}
+
+	output := generateToString(t, &config.Config{}, files)
+
+	for _, expected := range []string{
+		"**Path:** `a.go`  \n**Same file as:** `b.go`  \n\n",
+		"**Path:** `b.go`  \n**Same file as:** `a.go`  \n\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestGenerateMarkdown_MaxFilesPerDir(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 13, Content: "package main\n"}}
+	for i := range 20 {
+		files = append(files, gatherer.FileInfo{Path: fmt.Sprintf("fixtures/case%02d.json", i), Size: 3, Content: "{}\n"})
+	}
+
+	output := generateToString(t, &config.Config{MaxFilesPerDir: 5}, files)
+
+	if got := strings.Count(output, "### fixtures/"); got != 5 {
+		t.Errorf("Expected 5 fixtures sections, got %d", got)
+	}
+
+	if !strings.Contains(output, "### fixtures/case04.json") || strings.Contains(output, "### fixtures/case05.json") {
+		t.Errorf("Expected the alphabetically first fixtures to be kept, got:\n%s", output)
+	}
+
+	if !strings.Contains(output, "_(15 more files in `fixtures/` omitted)_") {
+		t.Errorf("Expected an omission note for fixtures, got:\n%s", output)
+	}
+
+	if strings.Contains(output, "in `./` omitted") {
+		t.Errorf("Expected no omission note for the root directory, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_Chart(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 200, Content: "package main\n"},
+		{Path: "util.go", Size: 100, Content: "package main\n"},
+		{Path: "README.md", Size: 100, Content: "# Readme\n"},
+	}
+
+	output := generateToString(t, &config.Config{Chart: true}, files)
+
+	goBar := "go       " + strings.Repeat("#", 30) + strings.Repeat(" ", 10) + "  75.0%\n"
+	markdownBar := "markdown " + strings.Repeat("#", 10) + strings.Repeat(" ", 30) + "  25.0%\n"
+
+	if !strings.Contains(output, "```text\n"+goBar+markdownBar+"```") {
+		t.Errorf("Expected a chart with go at three times the markdown bar, got:\n%s", output)
+	}
+
+	if strings.Contains(generateToString(t, &config.Config{}, files), "```text") {
+		t.Error("Expected no chart without the option")
+	}
+}
+
+func TestGenerateMarkdown_RawContent(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "raw.go", Size: 12, RawContent: []byte("package raw\n")},
+		{Path: "both.go", Size: 13, Content: "package both\n", RawContent: []byte("package stale\n")},
+	}
+
+	output := generateToString(t, &config.Config{}, files)
+
+	if !strings.Contains(output, "```go\npackage raw\n```") {
+		t.Errorf("Expected RawContent to be written, got:\n%s", output)
+	}
+
+	if !strings.Contains(output, "package both") || strings.Contains(output, "package stale") {
+		t.Errorf("Expected Content to take precedence over RawContent, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_RelativeAnchorIDs(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a/b.go", Size: 9, Content: "package a\n"},
+		{Path: "a-b.go", Size: 9, Content: "package a\n"},
+	}
+
+	if sanitizeAnchor(files[0].Path) != sanitizeAnchor(files[1].Path) {
+		t.Fatal("Expected the test paths to collide under path-based anchors")
+	}
+
+	output := generateToString(t, &config.Config{RelativeAnchorIDs: true}, files)
+
+	for i, file := range files {
+		anchor := fmt.Sprintf("file-%d", i+1)
+
+		if !strings.Contains(output, fmt.Sprintf("- [%s](#%s)", file.Path, anchor)) {
+			t.Errorf("Expected a TOC entry linking %s to #%s", file.Path, anchor)
+		}
+
+		if !strings.Contains(output, fmt.Sprintf("<a id=\"%s\"></a>\n\n### %s", anchor, file.Path)) {
+			t.Errorf("Expected anchor %s right before the %s section", anchor, file.Path)
+		}
+	}
+}
+
+func TestGenerateMarkdown_DeduplicatesAnchors(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a.b", Size: 2, Content: "x\n"},
+		{Path: "a_b", Size: 2, Content: "y\n"},
+		{Path: "a-b-1", Size: 2, Content: "z\n"},
+	}
+
+	output := generateToString(t, &config.Config{}, files)
+
+	// The literal a-b-1 path must not reuse the suffix already given to a_b.
+	expected := map[string]string{"a.b": "a-b", "a_b": "a-b-1", "a-b-1": "a-b-1-1"}
+
+	for path, anchor := range expected {
+		if !strings.Contains(output, fmt.Sprintf("- [%s](#%s)\n", path, anchor)) {
+			t.Errorf("Expected a TOC entry linking %s to #%s", path, anchor)
+		}
+
+		if !strings.Contains(output, fmt.Sprintf("<a id=\"%s\"></a>\n\n### %s\n", anchor, path)) {
+			t.Errorf("Expected anchor %s right before the %s section", anchor, path)
+		}
+	}
+}
+
+func TestGenerateMarkdown_DirReadmeLeadsGroup(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 13, Content: "package main\n"},
+		{Path: "pkg/Makefile", Size: 6, Content: "all:\n\n"},
+		{Path: "pkg/README.md", Size: 6, Content: "# pkg\n"},
+		{Path: "pkg/impl.go", Size: 12, Content: "package pkg\n"},
+		{Path: "pkg/sub/x.go", Size: 12, Content: "package sub\n"},
+		{Path: "pkg/z.go", Size: 12, Content: "package pkg\n"},
+	}
+
+	cfg := &config.Config{GroupBy: config.GroupByDirectory, IncludeDirReadmeContext: true}
+	output := generateToString(t, cfg, files)
+
+	var previous int
+
+	for _, marker := range []string{
+		"## `./`", "### main.go", "## `pkg/`", "### pkg/README.md", "### pkg/Makefile",
+		"### pkg/impl.go", "### pkg/z.go", "## `pkg/sub/`", "### pkg/sub/x.go",
+	} {
+		index := strings.Index(output, marker)
+		if index < previous {
+			t.Fatalf("Expected %q to follow the previous section, got:\n%s", marker, output)
+		}
+
+		previous = index
+	}
+}
+
+func TestWrapProse(t *testing.T) {
+	long := "The quick brown fox jumps over the lazy dog and keeps running far into the distance."
+	content := long + "\n```\n" + long + "\n```\n"
+
+	wrapped := wrapProse(content, 40)
+	lines := strings.Split(wrapped, "\n")
+
+	fenceStart := -1
+
+	for i, line := range lines {
+		if line == "```" {
+			fenceStart = i
+			break
+		}
+
+		if len(line) > 40 {
+			t.Errorf("Line %d exceeds width 40: %q", i, line)
+		}
+	}
+
+	if fenceStart < 2 {
+		t.Fatalf("Expected the long paragraph to wrap onto multiple lines, got:\n%s", wrapped)
+	}
+
+	if lines[fenceStart+1] != long {
+		t.Errorf("Expected fenced line to be left untouched, got %q", lines[fenceStart+1])
+	}
+
+	if strings.Join(strings.Fields(strings.Join(lines[:fenceStart], " ")), " ") != long {
+		t.Error("Expected wrapping to preserve every word in order")
+	}
+}
+
+func TestGenerateMarkdown_TrimTrailingWhitespace(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 40, Content: "package main  \n\nfunc main() {\t\n\tx := 1 \t \n}\n"}}
+
+	output := generateToString(t, &config.Config{TrimTrailingWhitespace: true}, files)
+
+	expected := "```go\npackage main\n\nfunc main() {\n\tx := 1\n}\n```"
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected trailing whitespace to be removed with leading indentation intact, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_BOM(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12, Content: "package main\n"}}
+
+	output := generateToString(t, &config.Config{BOM: true}, files)
+	if !strings.HasPrefix(output, "\xEF\xBB\xBF# Codebase Analysis") {
+		t.Errorf("Expected BOM bytes EF BB BF before the header, got prefix %q", output[:min(len(output), 20)])
+	}
+
+	output = generateToString(t, &config.Config{}, files)
+	if !strings.HasPrefix(output, "# Codebase Analysis") {
+		t.Errorf("Expected no BOM without --bom, got prefix %q", output[:min(len(output), 20)])
+	}
+}
+
+func TestGenerateMarkdown_OutputEncodingBOM(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12, Content: "package main\n"}}
+
+	output := generateToString(t, &config.Config{OutputEncoding: config.EncodingUTF8BOM}, files)
+	if !strings.HasPrefix(output, "\xEF\xBB\xBF# Codebase Analysis") {
+		t.Errorf("Expected BOM bytes EF BB BF before the header, got prefix %q", output[:min(len(output), 20)])
+	}
+
+	output = generateToString(t, &config.Config{OutputEncoding: config.EncodingUTF8}, files)
+	if !strings.HasPrefix(output, "# Codebase Analysis") {
+		t.Errorf("Expected no BOM for plain utf8, got prefix %q", output[:min(len(output), 20)])
+	}
+
+	output = generateToString(t, &config.Config{OutputEncoding: config.EncodingUTF8, BOM: true}, files)
+	if !strings.HasPrefix(output, "\xEF\xBB\xBF# Codebase Analysis") {
+		t.Errorf("Expected --bom to win over utf8, got prefix %q", output[:min(len(output), 20)])
+	}
+}
+
+func TestGenerateMarkdown_SeedPromptPreset(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12, Content: "package main\n"}}
+
+	output := generateToString(t, &config.Config{SeedPrompt: "review"}, files)
+
+	expected := seedPromptPresets()["review"] + "\n\n# Codebase Analysis"
+	if !strings.HasPrefix(output, expected) {
+		t.Errorf("Expected output to open with the review preset, got prefix %q", output[:min(len(output), 120)])
+	}
+}
+
+func TestGenerateMarkdown_ContentPrefixAndSuffix(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12, Content: "package main\n"}}
+
+	suffixFile := filepath.Join(t.TempDir(), "suffix.txt")
+	if err := os.WriteFile(suffixFile, []byte("Identify potential security vulnerabilities.\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write suffix file: %v", err)
+	}
+
+	cfg := &config.Config{
+		ContentPrefix:     "You are a senior Go engineer. Review the following codebase:",
+		ContentSuffixFile: suffixFile,
+	}
+	output := generateToString(t, cfg, files)
+
+	if !strings.HasPrefix(output, cfg.ContentPrefix+"\n\n# Codebase Analysis") {
+		t.Errorf("Expected output to open with the prefix, got prefix %q", output[:min(len(output), 120)])
+	}
+
+	if !strings.HasSuffix(output, "```\n\nIdentify potential security vulnerabilities.\n") {
+		t.Errorf("Expected output to end with the suffix file contents, got:\n%s", output)
+	}
+
+	cfg.ContentSuffix = "inline"
+	cfg.OutputFile = filepath.Join(t.TempDir(), "out.md")
+
+	if err := NewMarkdownGenerator(cfg).GenerateMarkdown(files, "/repo"); !errors.Is(err, ErrConflictingContentText) {
+		t.Errorf("Expected ErrConflictingContentText for both a suffix and a suffix file, got %v", err)
+	}
+}
+
+func TestPackChunks(t *testing.T) {
+	const maxTokens = 250
+
+	line := strings.Repeat("x", 39) + "\n" // 10 tokens per line.
+	files := []gatherer.FileInfo{
+		{Path: "big.go", Content: strings.Repeat(line, 100)},
+		{Path: "small.go", Content: "package small\n"},
+	}
+
+	chunks := packChunks(files, maxTokens, contentRules{})
+
+	if len(chunks) != 5 {
+		t.Fatalf("Expected 5 chunks, got %d", len(chunks))
+	}
+
+	for i, chunk := range chunks {
+		if chunk.ID != chunkID(i+1) {
+			t.Errorf("Expected chunk %d to have ID %q, got %q", i, chunkID(i+1), chunk.ID)
+		}
+
+		if chunk.Tokens > maxTokens {
+			t.Errorf("Chunk %s has %d tokens, exceeding the limit of %d", chunk.ID, chunk.Tokens, maxTokens)
+		}
+	}
+
+	if !strings.Contains(chunks[1].Content, "continued from chunk-0001") {
+		t.Errorf("Expected the second chunk to mark its continuation, got:\n%s", chunks[1].Content)
+	}
+
+	last := chunks[len(chunks)-1]
+	if last.Files[len(last.Files)-1].Path != "small.go" || last.Files[0].EndLine != 100 {
+		t.Errorf("Expected the last chunk to finish big.go and contain small.go, got %+v", last.Files)
+	}
+}
+
+func TestGenerateMarkdown_TemplateVars(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "review.tmpl")
+
+	tmpl := "Team: {{ .Vars.TEAM }}\n{{ range .Files }}- {{ .Path }} ({{ .Language }})\n{{ end }}"
+	if err := os.WriteFile(templatePath, []byte(tmpl), 0600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	cfg := &config.Config{
+		Template:     templatePath,
+		TemplateVars: map[string]string{"TEAM": "backend"},
+	}
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 12, Content: "package main\n"}}
+
+	output := generateToString(t, cfg, files)
+
+	if expected := "Team: backend\n- main.go (go)\n"; output != expected {
+		t.Errorf("Expected template output %q, got %q", expected, output)
+	}
+}
+
+func TestGenerateJSONLines(t *testing.T) {
+	cfg := &config.Config{OutputFile: filepath.Join(t.TempDir(), "out.jsonl")}
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 26, Content: "package main\n\nfunc main() {}\n", Language: "go"},
+		{Path: "README.md", Size: 7, Content: "# Title\n"},
+	}
+
+	if err := NewJSONGenerator(cfg).GenerateJSONLines(files); err != nil {
+		t.Fatalf("GenerateJSONLines() returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	if lines := strings.Count(string(data), "\n"); lines != len(files) {
+		t.Errorf("Expected one line per file (%d), got %d", len(files), lines)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	expected := []JSONFile{
+		{Path: "main.go", Size: 26, Language: "go", Content: "package main\n\nfunc main() {}\n"},
+		{Path: "README.md", Size: 7, Language: "markdown", Content: "# Title\n"},
+	}
+
+	for _, want := range expected {
+		var got JSONFile
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestGenerator_WritesToWriter(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "main.go", Size: 13, Content: "package main\n", Language: "go"}}
+	cfg := config.NewConfig()
+
+	generators := map[string]Generator{
+		"markdown":   NewMarkdownGenerator(cfg),
+		"json":       NewJSONGenerator(cfg),
+		"jsonl":      NewJSONLinesGenerator(cfg),
+		"llm-chunks": NewChunkGenerator(cfg),
+	}
+
+	for name, gen := range generators {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gen.Generate(&buf, files, "/repo"); err != nil {
+				t.Fatalf("Generate() returned an unexpected error: %v", err)
+			}
+
+			if !strings.Contains(buf.String(), "package main") {
+				t.Errorf("Expected output to contain the file content, got:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestGrepFiles(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Language: "go", Content: "package main\n\nfunc main() {\n\trun()\n}\n\nfunc run() {}\n"},
+		{Path: "types.go", Language: "go", Content: "package main\n\ntype T struct{}\n"},
+	}
+
+	files, err := GrepFiles(files, "func ")
+	if err != nil {
+		t.Fatalf("GrepFiles() returned an unexpected error: %v", err)
+	}
+
+	output := generateToString(t, config.NewConfig(), files)
+
+	for _, want := range []string{"3: func main() {", "7: func run() {}"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	for _, unwanted := range []string{"package main", "run()\n", "types.go"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("Expected output not to contain %q, got:\n%s", unwanted, output)
+		}
+	}
+
+	if _, err := GrepFiles(files, "("); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestGenerateMarkdown_GroupByTopLevelDir(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "go.mod", Size: 12, Content: "module repo\n"},
+		{Path: "services/auth/auth.go", Size: 13, Content: "package auth\n"},
+		{Path: "services/billing/billing.go", Size: 16, Content: "package billing\n"},
+		{Path: "tools/gen.go", Size: 14, Content: "package tools\n"},
+	}
+
+	cfg := &config.Config{GroupByDir: true}
+	output := generateToString(t, cfg, files)
+
+	var previous int
+
+	for _, marker := range []string{
+		"- `./`\n  - [go.mod]", "- `services/`\n  - [services/auth/auth.go]", "  - [services/billing/billing.go]",
+		"- `tools/`\n  - [tools/gen.go]",
+		"## `./`", "### go.mod", "## `services/`", "### services/auth/auth.go", "### services/billing/billing.go",
+		"## `tools/`", "### tools/gen.go",
+	} {
+		index := strings.Index(output, marker)
+		if index < previous {
+			t.Fatalf("Expected %q to follow the previous marker, got:\n%s", marker, output)
+		}
+
+		previous = index
+	}
+
+	if strings.Contains(output, "## `services/auth/`") {
+		t.Errorf("Expected files to be grouped by top-level directory only, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_PreserveNoFinalNewline(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a.txt", Size: 5, Content: "hello"},
+		{Path: "b.txt", Size: 6, Content: "world\n"},
+	}
+
+	output := generateToString(t, config.NewConfig(), files)
+	if strings.Contains(output, noFinalNewlineNote) {
+		t.Errorf("Expected no final newline note by default, got:\n%s", output)
+	}
+
+	cfg := config.NewConfig()
+	cfg.PreserveNoFinalNewline = true
+	output = generateToString(t, cfg, files)
+
+	if !strings.Contains(output, "hello\n```\n\n"+noFinalNewlineNote+"\n") {
+		t.Errorf("Expected a final newline note after a.txt, got:\n%s", output)
+	}
+
+	if strings.Count(output, noFinalNewlineNote) != 1 {
+		t.Errorf("Expected exactly one final newline note, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_TOCTable(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a|b.txt", Size: 5, Content: "hello", Language: "text"},
+		{Path: "main.go", Size: 29, Content: "package main\n\nfunc main() {}\n", Language: "go"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.TOCTable = true
+	output := generateToString(t, cfg, files)
+
+	want := "| File | Size | Lines | Language |\n| --- | --- | --- | --- |\n" +
+		"| [a\\|b.txt](#a\\|b-txt) | 5 B | 1 | text |\n" +
+		"| [main.go](#main-go) | 29 B | 3 | go |\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected TOC table:\n%s\ngot:\n%s", want, output)
+	}
+
+	if strings.Contains(output, "- [main.go]") {
+		t.Errorf("Expected no bullet list TOC, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_TOCHierarchy(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "cmd/code2md/main.go", Content: "package main\n"},
+		{Path: "internal/a/a.go", Content: "package a\n"},
+		{Path: "internal/a/b.go", Content: "package a\n"},
+		{Path: "internal/c.go", Content: "package internal\n"},
+		{Path: "main.go", Content: "package main\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.TOCHierarchy = true
+	output := generateToString(t, cfg, files)
+
+	want := "## Table of Contents\n\n" +
+		"- `cmd/`\n" +
+		"  - [code2md/main.go](#cmd-code2md-main-go)\n" +
+		"- `internal/`\n" +
+		"  - `a/`\n" +
+		"    - [a.go](#internal-a-a-go)\n" +
+		"    - [b.go](#internal-a-b-go)\n" +
+		"  - [c.go](#internal-c-go)\n" +
+		"- [main.go](#main-go)\n\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected hierarchical TOC:\n%s\ngot:\n%s", want, output)
+	}
+}
+
+func TestGenerateMarkdown_ProtobufFence(t *testing.T) {
+	files := []gatherer.FileInfo{{Path: "api/service.proto", Size: 19, Content: "syntax = \"proto3\";\n"}}
+
+	output := generateToString(t, config.NewConfig(), files)
+	if !strings.Contains(output, "```protobuf\nsyntax = \"proto3\";\n```") {
+		t.Errorf("Expected the .proto file to be fenced as protobuf, got:\n%s", output)
+	}
+}
+
+func TestGenerateMarkdown_WithTransformer(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Language: "go", Content: "package main\n\nfunc main() {\n\tlog.Debug(\"x\")\n}\n"},
+		{Path: "app.env", Language: "dotenv", Content: "HOME=$HOME\n"},
+	}
+
+	stripDebug := func(_, language, content string) string {
+		if language != "go" {
+			return content
+		}
+
+		lines := strings.SplitAfter(content, "\n")
+
+		return strings.Join(slices.DeleteFunc(lines, func(line string) bool {
+			return strings.Contains(line, "log.Debug")
+		}), "")
+	}
+
+	expandHome := func(path, _, content string) string {
+		if path != "app.env" {
+			return content
+		}
+
+		return strings.ReplaceAll(content, "$HOME", "/home/user")
+	}
+
+	cfg := config.NewConfig()
+	cfg.OutputFile = filepath.Join(t.TempDir(), "out.md")
+
+	err := NewMarkdownGenerator(cfg, WithTransformer(stripDebug), WithTransformer(expandHome)).GenerateMarkdown(files, "/repo")
+	if err != nil {
+		t.Fatalf("GenerateMarkdown() returned an unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	output := string(data)
+	if strings.Contains(output, "log.Debug") || !strings.Contains(output, "func main() {\n}\n") {
+		t.Errorf("Expected debug logging to be stripped from main.go, got:\n%s", output)
+	}
+
+	if !strings.Contains(output, "HOME=/home/user\n") {
+		t.Errorf("Expected $HOME to be expanded in app.env, got:\n%s", output)
+	}
+}
+
+// failingWriter accepts limit bytes and fails every write after that.
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("disk full")
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.limit {
+		n := fw.limit
+		fw.limit = 0
+
+		return n, errWriteFailed
+	}
+
+	fw.limit -= len(p)
+
+	return len(p), nil
+}
+
+func TestGenerate_ErrorContext(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "small.go", Content: "package small\n"},
+		{Path: "big.go", Content: "package big\n" + strings.Repeat("// filler\n", 1000)},
+	}
+
+	err := NewMarkdownGenerator(config.NewConfig()).Generate(&failingWriter{limit: 100}, files, "/repo")
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("Expected the write error to be wrapped, got %v", err)
+	}
+
+	var genErr *GenerateError
+	if !errors.As(err, &genErr) || genErr.Phase != "file contents" {
+		t.Fatalf("Expected a GenerateError for the file contents phase, got %#v", err)
+	}
+
+	if !strings.Contains(err.Error(), "big.go") {
+		t.Errorf("Expected the error to name the file being written, got %q", err)
+	}
+}
+
+func TestCollapseBlankLines(t *testing.T) {
 	testCases := []struct {
 		name     string
-		path     string
+		content  string
 		expected string
 	}{
-		{"Go file", "main.go", "go"},
-		{"Dockerfile", "Dockerfile", "dockerfile"},
+		{"Five newlines", "a\n\n\n\n\nb\n", "a\n\nb\n"},
+		{"Single blank line", "a\n\nb\n", "a\n\nb\n"},
+		{"Whitespace-only lines", "a\n  \n\t\n\nb", "a\n  \nb"},
+		{"Trailing blank lines", "a\n\n\n", "a\n\n"},
+		{"No blank lines", "a\nb\n", "a\nb\n"},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual := getLanguageFromPath(tc.path)
-			if actual != tc.expected {
-				t.Errorf("getLanguageFromPath(%q): expected %q, got %q", tc.path, tc.expected, actual)
+			if actual := collapseBlankLines(tc.content); actual != tc.expected {
+				t.Errorf("collapseBlankLines(%q): expected %q, got %q", tc.content, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGenerateParts(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "a.go", Size: 40, Content: "package a\n"},
+		{Path: "b.go", Size: 40, Content: "package b\n"},
+		{Path: "c.go", Size: 120, Content: "package c\n"},
+		{Path: "d.go", Size: 10, Content: "package d\n"},
+	}
+
+	dir := t.TempDir()
+	cfg := config.NewConfig()
+	cfg.OutputFile = filepath.Join(dir, "codebase.md")
+	cfg.SplitSize = 100
+
+	paths, err := NewMarkdownGenerator(cfg).GenerateParts(files, "/repo")
+	if err != nil {
+		t.Fatalf("GenerateParts() returned an unexpected error: %v", err)
+	}
+
+	expected := []string{"codebase-part1.md", "codebase-part2.md", "codebase-part3.md", "codebase-index.md"}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, paths)
+	}
+
+	for i, name := range expected[:3] {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		output := string(data)
+		if !strings.Contains(output, fmt.Sprintf("**Part %d of 3**", i+1)) {
+			t.Errorf("Expected %s to state part %d of 3, got:\n%s", name, i+1, output)
+		}
+
+		if !strings.Contains(output, "[Part 3](codebase-part3.md): `d.go`") && i != 2 {
+			t.Errorf("Expected %s to list the files of part 3, got:\n%s", name, output)
+		}
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "codebase-index.md"))
+	if err != nil {
+		t.Fatalf("Failed to read the index: %v", err)
+	}
+
+	if !strings.Contains(string(index), "split into 3 parts") || !strings.Contains(string(index), "## [Part 2](codebase-part2.md)") {
+		t.Errorf("Expected the index to summarize 3 parts, got:\n%s", index)
+	}
+}
+
+func TestGenerateParts_MaxFilesPerDir(t *testing.T) {
+	var files []gatherer.FileInfo
+	for i := 1; i <= 5; i++ {
+		files = append(files, gatherer.FileInfo{Path: fmt.Sprintf("src/s%d.go", i), Size: 60, Content: "package src\n"})
+	}
+
+	dir := t.TempDir()
+	cfg := config.NewConfig()
+	cfg.OutputFile = filepath.Join(dir, "codebase.md")
+	cfg.SplitSize = 100
+	cfg.MaxFilesPerDir = 3
+
+	paths, err := NewMarkdownGenerator(cfg).GenerateParts(files, "/repo")
+	if err != nil {
+		t.Fatalf("GenerateParts() returned an unexpected error: %v", err)
+	}
+
+	// Three sampled files of 60 bytes give three parts, plus the index.
+	if len(paths) != 4 {
+		t.Fatalf("Expected 3 parts and an index, got %v", paths)
+	}
+
+	var parts strings.Builder
+
+	for _, p := range paths[:3] {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", p, err)
+		}
+
+		parts.Write(data)
+	}
+
+	output := parts.String()
+	if strings.Contains(output, "### src/s4.go") || strings.Contains(output, "### src/s5.go") {
+		t.Errorf("Expected the parts to keep only the first 3 files of src/, got:\n%s", output)
+	}
+
+	if n := strings.Count(output, "_(2 more files in `src/` omitted)_"); n != 1 {
+		t.Errorf("Expected one note for the 2 omitted files, found %d in:\n%s", n, output)
+	}
+}
+
+func TestGenerateByDir(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "README.md", Size: 8, Content: "# Readme"},
+		{Path: "cmd/main.go", Size: 12, Content: "package main"},
+		{Path: "internal/a/a.go", Size: 9, Content: "package a"},
+		{Path: "internal/b.go", Size: 9, Content: "package b"},
+	}
+
+	dir := t.TempDir()
+	cfg := config.NewConfig()
+	cfg.OutputFile = filepath.Join(dir, "codebase.md")
+	cfg.SplitByDir = true
+
+	paths, err := NewMarkdownGenerator(cfg).GenerateByDir(files, "/repo")
+	if err != nil {
+		t.Fatalf("GenerateByDir() returned an unexpected error: %v", err)
+	}
+
+	dirsDir := filepath.Join(dir, "codebase-dirs")
+
+	expected := []string{cfg.OutputFile, filepath.Join(dirsDir, "cmd.md"), filepath.Join(dirsDir, "internal.md")}
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("Expected outputs %v, got %v", expected, paths)
+	}
+
+	contents := map[string][]string{
+		"codebase.md":               {"README.md"},
+		"codebase-dirs/cmd.md":      {"cmd/main.go"},
+		"codebase-dirs/internal.md": {"internal/a/a.go", "internal/b.go"},
+	}
+
+	for name, want := range contents {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		output := string(data)
+		if !strings.Contains(output, "# Codebase Analysis") {
+			t.Errorf("Expected %s to be a complete document, got:\n%s", name, output)
+		}
+
+		for _, file := range files {
+			if got := strings.Contains(output, "## "+file.Path); got != slices.Contains(want, file.Path) {
+				t.Errorf("%s: contains section for %s = %v, want %v", name, file.Path, got, !got)
+			}
+		}
+	}
+}
+
+func TestGenerateByDir_KeepsUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.NewConfig()
+	cfg.OutputFile = filepath.Join(dir, "codebase.md")
+	cfg.SplitByDir = true
+
+	// A document of the user's own, named like the docs directory's document.
+	if err := os.WriteFile(filepath.Join(dir, "docs.md"), []byte("# My notes\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write docs.md: %v", err)
+	}
+
+	files := []gatherer.FileInfo{{Path: "docs/guide.md", Size: 7, Content: "# Guide"}}
+	if _, err := NewMarkdownGenerator(cfg).GenerateByDir(files, "/repo"); err != nil {
+		t.Fatalf("GenerateByDir() returned an unexpected error: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "docs.md")); err != nil || string(data) != "# My notes\n" {
+		t.Errorf("Expected docs.md to be left alone, got %q (err=%v)", data, err)
+	}
+
+	collide := []gatherer.FileInfo{
+		{Path: "Docs/a.md", Size: 1, Content: "a"},
+		{Path: "docs/b.md", Size: 1, Content: "b"},
+	}
+	if _, err := NewMarkdownGenerator(cfg).GenerateByDir(collide, "/repo"); !errors.Is(err, ErrDirOutputCollision) {
+		t.Errorf("Expected ErrDirOutputCollision for Docs and docs, got %v", err)
+	}
+}
+
+// validateJSONSchema checks value against the subset of JSON Schema used by JSONSchema:
+// type, properties, required, additionalProperties, items, and pattern.
+func validateJSONSchema(t *testing.T, where string, schema map[string]any, value any) {
+	t.Helper()
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: expected an object, got %T", where, value)
+			return
+		}
+
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				t.Errorf("%s: missing required property %q", where, name)
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]any)
+
+		for name, propValue := range obj {
+			if propSchema, ok := properties[name].(map[string]any); ok {
+				validateJSONSchema(t, where+"."+name, propSchema, propValue)
+			} else if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+				validateJSONSchema(t, where+"."+name, extra, propValue)
+			} else {
+				t.Errorf("%s: unexpected property %q", where, name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			t.Errorf("%s: expected an array, got %T", where, value)
+			return
+		}
+
+		for i, item := range items {
+			validateJSONSchema(t, fmt.Sprintf("%s[%d]", where, i), schema["items"].(map[string]any), item)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			t.Errorf("%s: expected a string, got %T", where, value)
+		} else if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			t.Errorf("%s: %q does not match %q", where, s, pattern)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			t.Errorf("%s: expected an integer, got %v", where, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: expected a boolean, got %T", where, value)
+		}
+	default:
+		t.Fatalf("%s: unsupported schema type %v", where, schema["type"])
+	}
+}
+
+func TestJSONSchema_ValidatesOutput(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 13, Content: "package main\n"},
+		{Path: "README.md", Size: 9, Content: "# Readme\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.RepoName = "repo"
+	cfg.NoContentFor = []string{"README.md"}
+
+	outputs := map[string]Generator{
+		config.FormatJSON:      NewJSONGenerator(cfg),
+		config.FormatJSONL:     NewJSONLinesGenerator(cfg),
+		config.FormatLLMChunks: NewChunkGenerator(cfg),
+	}
+
+	for format, gen := range outputs {
+		var buf bytes.Buffer
+		if err := gen.Generate(&buf, files, "/repo"); err != nil {
+			t.Fatalf("%s: Generate() returned an unexpected error: %v", format, err)
+		}
+
+		text, err := JSONSchema(format)
+		if err != nil {
+			t.Fatalf("JSONSchema(%q) returned an unexpected error: %v", format, err)
+		}
+
+		var schema map[string]any
+		if err := json.Unmarshal([]byte(text), &schema); err != nil {
+			t.Fatalf("%s: the schema is not valid JSON: %v", format, err)
+		}
+
+		// A jsonl output holds one document per line.
+		documents := []string{buf.String()}
+		if format == config.FormatJSONL {
+			documents = strings.Split(strings.TrimSpace(buf.String()), "\n")
+		}
+
+		for _, doc := range documents {
+			var value any
+			if err := json.Unmarshal([]byte(doc), &value); err != nil {
+				t.Fatalf("%s: the output is not valid JSON: %v", format, err)
+			}
+
+			validateJSONSchema(t, format, schema, value)
+		}
+	}
+
+	if _, err := JSONSchema(config.FormatMarkdown); !errors.Is(err, ErrNoJSONSchema) {
+		t.Errorf("Expected ErrNoJSONSchema for markdown, got %v", err)
+	}
+}
+
+func TestGenerate_NoContentForNonMarkdown(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Size: 13, Content: "package main\n"},
+		{Path: "secret.txt", Size: 8, Content: "hunter2\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.NoContentFor = []string{"secret.txt"}
+
+	outputs := map[string]Generator{
+		config.FormatJSON:      NewJSONGenerator(cfg),
+		config.FormatJSONL:     NewJSONLinesGenerator(cfg),
+		config.FormatLLMChunks: NewChunkGenerator(cfg),
+	}
+
+	for format, gen := range outputs {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gen.Generate(&buf, files, "/repo"); err != nil {
+				t.Fatalf("Generate() returned an unexpected error: %v", err)
+			}
+
+			output := buf.String()
+			if strings.Contains(output, "hunter2") {
+				t.Errorf("Expected the content of secret.txt to be omitted, got:\n%s", output)
+			}
+
+			for _, want := range []string{"secret.txt", "package main"} {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected the output to contain %q, got:\n%s", want, output)
+				}
+			}
+
+			if !regexp.MustCompile(`"omitted": ?true`).MatchString(output) {
+				t.Errorf("Expected secret.txt to be marked as omitted, got:\n%s", output)
 			}
 		})
 	}
 }
+
+func TestGenerate_RelativeSizeBar(t *testing.T) {
+	files := []gatherer.FileInfo{
+		{Path: "big.go", Size: 4000, Content: "package big\n"},
+		{Path: "half.go", Size: 2000, Content: "package half\n"},
+		{Path: "tiny.go", Size: 10, Content: "package t\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.RelativeSizeBar = true
+
+	var buf bytes.Buffer
+	if err := NewMarkdownGenerator(cfg).Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for path, bar := range map[string]string{
+		"big.go":  strings.Repeat("#", sizeBarWidth),
+		"half.go": strings.Repeat("#", sizeBarWidth/2) + strings.Repeat(".", sizeBarWidth/2),
+		"tiny.go": "#" + strings.Repeat(".", sizeBarWidth-1),
+	} {
+		section := output[strings.Index(output, "### "+path):]
+		if !strings.Contains(section[:strings.Index(section, "```")], "**Relative Size:** `"+bar+"`") {
+			t.Errorf("Expected %s to have the bar %q, got:\n%s", path, bar, section)
+		}
+	}
+
+	cfg.RelativeSizeBar = false
+	buf.Reset()
+
+	if err := NewMarkdownGenerator(cfg).Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "Relative Size") {
+		t.Errorf("Expected no size bars by default, got:\n%s", buf.String())
+	}
+}
+
+func TestGenerate_AnnotateImports(t *testing.T) {
+	goSource := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\t\"path/filepath\"\n\n\t\"github.com/spf13/cobra\"\n)\n"
+	files := []gatherer.FileInfo{
+		{Path: "main.go", Content: goSource},
+		{Path: "app.py", Content: "import os, sys\nfrom collections import OrderedDict\n\nprint(1)\n"},
+		{Path: "web/app.ts", Content: "import { a } from './a';\nimport React from \"react\";\nconst fs = require('fs');\n"},
+		{Path: "lib/user.rb", Content: "require 'json'\nrequire_relative \"helper\"\n"},
+		{Path: "notes.md", Content: "# import nothing\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.AnnotateImports = true
+
+	var buf bytes.Buffer
+	if err := NewMarkdownGenerator(cfg).Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	output := buf.String()
+	for path, comment := range map[string]string{
+		"main.go":     "<!-- imports: fmt, os, path/filepath, github.com/spf13/cobra -->\n```go\n",
+		"app.py":      "<!-- imports: os, sys, collections -->\n```python\n",
+		"web/app.ts":  "<!-- imports: ./a, react, fs -->\n```typescript\n",
+		"lib/user.rb": "<!-- imports: json, helper -->\n```ruby\n",
+	} {
+		section := output[strings.Index(output, "### "+path):]
+		if !strings.Contains(section, comment) {
+			t.Errorf("Expected %s to be annotated with %q, got:\n%s", path, comment, section)
+		}
+	}
+
+	if section := output[strings.Index(output, "### notes.md"):]; strings.Contains(section, "<!-- imports") {
+		t.Errorf("Expected no imports comment for a markdown file, got:\n%s", section)
+	}
+
+	cfg.AnnotateImports = false
+	buf.Reset()
+
+	if err := NewMarkdownGenerator(cfg).Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "<!-- imports") {
+		t.Errorf("Expected no imports comments by default, got:\n%s", buf.String())
+	}
+}
+
+func TestGenerate_Summarize(t *testing.T) {
+	goSource := `package server
+
+// Server serves requests.
+type Server struct {
+	addr string
+}
+
+// Start listens on the server's address.
+func (s *Server) Start(ctx context.Context) error {
+	secretBodyMarker := s.addr
+	return listen(ctx, secretBodyMarker)
+}
+
+func listen(ctx context.Context, addr string) error {
+	return nil
+}
+
+type IDs []int
+
+type Key [16]byte
+`
+
+	var long strings.Builder
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+
+	files := []gatherer.FileInfo{
+		{Path: "server.go", Content: goSource},
+		{Path: "data.txt", Content: long.String()},
+		{Path: "main.go", Content: "package main\n\nfunc main() {\n\tkeptBodyMarker()\n}\n"},
+	}
+
+	cfg := config.NewConfig()
+	cfg.Summarize = []string{"server.go", "*.txt"}
+
+	var buf bytes.Buffer
+	if err := NewMarkdownGenerator(cfg).Generate(&buf, files, "/repo"); err != nil {
+		t.Fatalf("Generate() returned an unexpected error: %v", err)
+	}
+
+	output := buf.String()
+
+	for _, want := range []string{
+		"func (s *Server) Start(ctx context.Context) error\n",
+		"func listen(ctx context.Context, addr string) error\n",
+		"type Server struct\n",
+		"type IDs slice\n",
+		"type Key array\n",
+		"line 10\n... (10 lines omitted) ...\nline 21\n",
+		"keptBodyMarker()",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	for _, unwanted := range []string{"secretBodyMarker", "line 11\n", "addr string\n"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("Expected the summarized bodies to be left out, found %q in:\n%s", unwanted, output)
+		}
+	}
+}
+
+func TestWriteFile_FailureKeepsExistingOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "codebase.md")
+
+	if err := os.WriteFile(outputPath, []byte("previous output\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write the previous output: %v", err)
+	}
+
+	cfg := config.NewConfig()
+	cfg.GroupBy = "language"
+
+	files := []gatherer.FileInfo{{Path: "main.go", Content: "package main\n"}}
+	if err := WriteFile(NewMarkdownGenerator(cfg), outputPath, files, "/repo"); !errors.Is(err, ErrUnknownGroupBy) {
+		t.Fatalf("Expected ErrUnknownGroupBy, got %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil || string(data) != "previous output\n" {
+		t.Errorf("Expected the previous output to be kept, got %q (%v)", data, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left behind, got %v (%v)", entries, err)
+	}
+
+	cfg.GroupBy = ""
+	if err := WriteFile(NewMarkdownGenerator(cfg), outputPath, files, "/repo"); err != nil {
+		t.Fatalf("WriteFile() returned an unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the replaced output to keep its permissions, got %v (%v)", info, err)
+	}
+}
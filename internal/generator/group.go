@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownGroupBy is returned when the configured grouping is not supported.
+var ErrUnknownGroupBy = errors.New("unknown group-by value")
+
+// dirReadme is the file name that leads its directory group with IncludeDirReadmeContext.
+const dirReadme = "readme.md"
+
+// validateGroupBy rejects unsupported values of Config.GroupBy.
+func validateGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", config.GroupByDirectory, config.GroupByTopLevel:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (expected %s or %s)",
+			ErrUnknownGroupBy, groupBy, config.GroupByDirectory, config.GroupByTopLevel)
+	}
+}
+
+// groupBy returns the effective grouping; GroupByDir is shorthand for top-level grouping.
+func (mg *MarkdownGenerator) groupBy() string {
+	if mg.config.GroupBy == "" && mg.config.GroupByDir {
+		return config.GroupByTopLevel
+	}
+
+	return mg.config.GroupBy
+}
+
+// groupOf returns the directory group a file belongs to, or "" when files are not grouped.
+// Files in the root directory belong to the "." group.
+func (mg *MarkdownGenerator) groupOf(file gatherer.FileInfo) string {
+	switch mg.groupBy() {
+	case config.GroupByDirectory:
+		return path.Dir(file.Path)
+	case config.GroupByTopLevel:
+		if top, _, found := strings.Cut(file.Path, "/"); found {
+			return top
+		}
+
+		return "."
+	default:
+		return ""
+	}
+}
+
+// orderFiles returns the files in output order. Grouped files are kept together by
+// directory, and with IncludeDirReadmeContext the README.md of a group's directory leads
+// the group so that it serves as the description of the code that follows.
+func (mg *MarkdownGenerator) orderFiles(files []gatherer.FileInfo) []gatherer.FileInfo {
+	if mg.groupBy() == "" {
+		return files
+	}
+
+	ordered := slices.Clone(files)
+
+	slices.SortStableFunc(ordered, func(a, b gatherer.FileInfo) int {
+		group := mg.groupOf(a)
+		if c := strings.Compare(group, mg.groupOf(b)); c != 0 {
+			return c
+		}
+
+		if mg.config.IncludeDirReadmeContext {
+			aReadme := isGroupReadme(a.Path, group)
+			bReadme := isGroupReadme(b.Path, group)
+
+			switch {
+			case aReadme && !bReadme:
+				return -1
+			case bReadme && !aReadme:
+				return 1
+			}
+		}
+
+		return 0
+	})
+
+	return ordered
+}
+
+// isGroupReadme reports whether the file at p is the README.md of the group's own directory.
+func isGroupReadme(p, group string) bool {
+	return path.Dir(p) == group && strings.EqualFold(path.Base(p), dirReadme)
+}
+
+// sampleFiles keeps at most MaxFilesPerDir files of each directory, alphabetically first,
+// and returns the number of files omitted from each directory. Files must be sorted by path.
+func (mg *MarkdownGenerator) sampleFiles(files []gatherer.FileInfo) ([]gatherer.FileInfo, map[string]int) {
+	limit := mg.config.MaxFilesPerDir
+	if limit <= 0 {
+		return files, nil
+	}
+
+	kept := make([]gatherer.FileInfo, 0, len(files))
+	counts := make(map[string]int)
+	omitted := make(map[string]int)
+
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+
+		counts[dir]++
+		if counts[dir] > limit {
+			omitted[dir]++
+			continue
+		}
+
+		kept = append(kept, file)
+	}
+
+	return kept, omitted
+}
+
+// writeOmissionNote notes how many files of dir were left out by MaxFilesPerDir.
+func writeOmissionNote(writer io.Writer, dir string, count int) error {
+	_, err := fmt.Fprintf(writer, "_(%d more files in `%s/` omitted)_\n\n", count, dir)
+	return err
+}
@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Import statements of languages without a parser in the standard library. Each pattern
+// captures the imported module in one of its groups.
+var (
+	pythonImport = regexp.MustCompile(`(?m)^[ \t]*(?:from[ \t]+([\w.]+)[ \t]+import|import[ \t]+([\w.]+(?:[ \t]*,[ \t]*[\w.]+)*))`)
+	jsImport     = regexp.MustCompile(`(?m)^\s*import\s+(?:[^'";]*?\s+from\s+)?['"]([^'"\n]+)['"]|require\(\s*['"]([^'"\n]+)['"]\s*\)`)
+	javaImport   = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+(?:static[ \t]+)?([\w.*]+)`)
+	rubyImport   = regexp.MustCompile(`(?m)^[ \t]*require(?:_relative)?[ \t(]+['"]([^'"\n]+)['"]`)
+	rustImport   = regexp.MustCompile(`(?m)^[ \t]*(?:pub[ \t]+)?use[ \t]+([\w:]+)`)
+	cImport      = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*[<"]([^>"\n]+)[>"]`)
+	csharpImport = regexp.MustCompile(`(?m)^[ \t]*using[ \t]+(?:static[ \t]+)?([\w.]+)[ \t]*;`)
+	phpImport    = regexp.MustCompile(`(?m)^[ \t]*use[ \t]+([\w\\]+)`)
+)
+
+// importPattern returns the import pattern of a language, or nil when imports of the
+// language are not detected.
+func importPattern(lang string) *regexp.Regexp {
+	switch lang {
+	case "python":
+		return pythonImport
+	case "javascript", "typescript", "jsx", "tsx", "vue":
+		return jsImport
+	case "java", "kotlin", "scala", "groovy":
+		return javaImport
+	case "ruby":
+		return rubyImport
+	case "rust":
+		return rustImport
+	case "c", "cpp":
+		return cImport
+	case "csharp":
+		return csharpImport
+	case "php":
+		return phpImport
+	default:
+		return nil
+	}
+}
+
+// fileImports returns the modules that a file of the given language imports, in order
+// of appearance and without duplicates. Go files are parsed; other languages are
+// scanned with a regular expression, which may miss unusual import forms.
+func fileImports(path, content, lang string) []string {
+	if lang == "go" {
+		return goImports(path, content)
+	}
+
+	pattern := importPattern(lang)
+	if pattern == nil {
+		return nil
+	}
+
+	var imports []string
+
+	seen := make(map[string]bool)
+
+	for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+		for _, group := range match[1:] {
+			// Python lists several modules in one statement: import os, sys.
+			for _, name := range strings.Split(group, ",") {
+				name = strings.TrimSpace(name)
+				if name != "" && !seen[name] {
+					seen[name] = true
+					imports = append(imports, name)
+				}
+			}
+		}
+	}
+
+	return imports
+}
+
+// goImports returns the import paths of a Go file. A file with syntax errors yields
+// the imports parsed before the first error.
+func goImports(path, content string) []string {
+	file, _ := parser.ParseFile(token.NewFileSet(), path, content, parser.ImportsOnly)
+	if file == nil {
+		return nil
+	}
+
+	imports := make([]string, 0, len(file.Imports))
+
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, importPath)
+		}
+	}
+
+	return imports
+}
@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"bufio"
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSONDocument is the top-level structure written by JSONGenerator.
+type JSONDocument struct {
+	Name       string     `json:"name,omitempty"`
+	Repository string     `json:"repository"`
+	Generated  time.Time  `json:"generated"`
+	FileCount  int        `json:"file_count"`
+	TotalSize  int64      `json:"total_size"`
+	Files      []JSONFile `json:"files"`
+}
+
+// JSONFile describes a single gathered file in the JSON output.
+type JSONFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+	// Omitted reports that the file matches NoContentFor, so Content is left empty.
+	Omitted bool `json:"omitted,omitempty"`
+}
+
+// JSONGenerator is responsible for creating the JSON file.
+type JSONGenerator struct {
+	config *config.Config
+}
+
+// NewJSONGenerator creates a new JSONGenerator.
+func NewJSONGenerator(cfg *config.Config) *JSONGenerator {
+	return &JSONGenerator{config: cfg}
+}
+
+// GenerateJSON creates the final JSON file from the gathered file info.
+func (jg *JSONGenerator) GenerateJSON(files []gatherer.FileInfo, rootPath string) error {
+	return WriteFile(jg, jg.config.OutputFile, files, rootPath)
+}
+
+// Generate writes the JSON document for the gathered files to w.
+func (jg *JSONGenerator) Generate(w io.Writer, files []gatherer.FileInfo, rootPath string) error {
+	rules, err := newContentRules(jg.config)
+	if err != nil {
+		return err
+	}
+
+	doc := JSONDocument{
+		Name:       jg.config.RepoName,
+		Repository: rootPath,
+		Generated:  time.Now(),
+		FileCount:  len(files),
+		TotalSize:  calculateTotalSize(files),
+		Files:      make([]JSONFile, len(files)),
+	}
+
+	for i, file := range files {
+		doc.Files[i] = newJSONFile(file, rules)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return phaseError("JSON document", encoder.Encode(doc))
+}
+
+// GenerateJSONLines writes one JSON object per gathered file, one per line, with no
+// enclosing document, so consumers can process the output incrementally.
+func (jg *JSONGenerator) GenerateJSONLines(files []gatherer.FileInfo) error {
+	return WriteFile(NewJSONLinesGenerator(jg.config), jg.config.OutputFile, files, "")
+}
+
+// JSONLinesGenerator is responsible for creating the JSON Lines file.
+type JSONLinesGenerator struct {
+	config *config.Config
+}
+
+// NewJSONLinesGenerator creates a new JSONLinesGenerator.
+func NewJSONLinesGenerator(cfg *config.Config) *JSONLinesGenerator {
+	return &JSONLinesGenerator{config: cfg}
+}
+
+// Generate writes one JSON object per gathered file to w. The root path is not part of the output.
+func (jg *JSONLinesGenerator) Generate(w io.Writer, files []gatherer.FileInfo, _ string) error {
+	rules, err := newContentRules(jg.config)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+
+	for _, file := range files {
+		if err := encoder.Encode(newJSONFile(file, rules)); err != nil {
+			return phaseError("JSON line", fmt.Errorf("%s: %w", file.Path, err))
+		}
+	}
+
+	return phaseError("output", writer.Flush())
+}
+
+// newJSONFile converts a gathered file to its JSON representation, leaving out the
+// content of files that the rules omit.
+func newJSONFile(file gatherer.FileInfo, rules contentRules) JSONFile {
+	jsonFile := JSONFile{
+		Path:     file.Path,
+		Size:     file.Size,
+		Language: languageOf(file),
+	}
+
+	if rules.mode(file.Path) == contentOmitted {
+		jsonFile.Omitted = true
+	} else {
+		jsonFile.Content = file.Text()
+	}
+
+	return jsonFile
+}
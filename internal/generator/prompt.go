@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrConflictingContentText is returned when both a text and a file are given for the same injected text.
+var ErrConflictingContentText = errors.New("text and file are mutually exclusive")
+
+// seedPromptPresets are the built-in instruction blocks selectable with --seed-prompt.
+func seedPromptPresets() map[string]string {
+	return map[string]string{
+		"review": "You are reviewing the following codebase. Read it carefully and answer questions about it. " +
+			"Point out design issues, risky code, and opportunities for simplification.",
+		"explain": "You are helping a newcomer understand the following codebase. " +
+			"Explain its purpose, architecture, and how the main components fit together.",
+		"document": "You are writing documentation for the following codebase. " +
+			"Produce clear, accurate documentation for its public interfaces and main workflows.",
+		"find-bugs": "You are auditing the following codebase for defects. " +
+			"Identify bugs, edge cases, and error-handling gaps, citing the file and the relevant code for each.",
+	}
+}
+
+// resolveSeedPrompt returns the instruction text for a preset name or, failing that,
+// the contents of the file at the given path.
+func resolveSeedPrompt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	if preset, ok := seedPromptPresets()[value]; ok {
+		return preset, nil
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return "", fmt.Errorf("seed prompt %q is neither a preset (review, explain, document, find-bugs) nor a readable file: %w",
+			value, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveContentText returns the literal text or, when file is set, the trimmed contents
+// of that file. name identifies the setting in errors.
+func resolveContentText(text, file, name string) (string, error) {
+	if file == "" {
+		return text, nil
+	}
+
+	if text != "" {
+		return "", fmt.Errorf("%w: set either --%s or --%s-file", ErrConflictingContentText, name, name)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read --%s-file: %w", name, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
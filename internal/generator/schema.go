@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"bytes"
+	"code2md/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoJSONSchema is returned when a JSON Schema is requested for a format that is not JSON.
+var ErrNoJSONSchema = errors.New("no JSON Schema for format")
+
+// jsonFileSchema describes a JSONFile in the files of the json format.
+const jsonFileSchema = `{
+        "type": "object",
+        "description": "A gathered file.",
+        "properties": {
+          "path": {"type": "string", "description": "Path relative to the scanned directory."},
+          "size": {"type": "integer", "description": "File size in bytes."},
+          "language": {"type": "string", "description": "Language identifier, as used for markdown code fences."},
+          "content": {"type": "string", "description": "File content, empty when omitted."},
+          "omitted": {"type": "boolean", "description": "Whether the content was left out by no-content-for."}
+        },
+        "required": ["path", "size", "language", "content"],
+        "additionalProperties": false
+      }`
+
+// jsonDocumentSchema describes the JSONDocument written by the json format.
+const jsonDocumentSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/chunghha/code2md/schemas/json.schema.json",
+  "title": "code2md JSON output",
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "description": "Repository name."},
+    "repository": {"type": "string", "description": "Absolute path of the scanned directory."},
+    "generated": {"type": "string", "format": "date-time"},
+    "file_count": {"type": "integer"},
+    "total_size": {"type": "integer", "description": "Total size of the files in bytes."},
+    "files": {
+      "type": "array",
+      "items": ` + jsonFileSchema + `
+    }
+  },
+  "required": ["repository", "generated", "file_count", "total_size", "files"],
+  "additionalProperties": false
+}
+`
+
+// jsonLineHeader holds the top-level keywords of the jsonl schema, which otherwise is
+// jsonFileSchema, since each line of the jsonl format is one JSONFile.
+const jsonLineHeader = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/chunghha/code2md/schemas/jsonl.schema.json",
+  "title": "code2md JSON Lines output (one line)",`
+
+// jsonLineSchema returns the schema of one line of the jsonl format, built from jsonFileSchema
+// and indented as a top-level document.
+func jsonLineSchema() (string, error) {
+	schema := jsonLineHeader + strings.TrimPrefix(jsonFileSchema, "{")
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(schema), "", "  "); err != nil {
+		return "", err
+	}
+
+	return indented.String() + "\n", nil
+}
+
+// chunkDocumentSchema describes the ChunkDocument written by the llm-chunks format.
+const chunkDocumentSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/chunghha/code2md/schemas/llm-chunks.schema.json",
+  "title": "code2md LLM chunks output",
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "description": "Repository name."},
+    "repository": {"type": "string", "description": "Absolute path of the scanned directory."},
+    "generated": {"type": "string", "format": "date-time"},
+    "chunk_tokens": {"type": "integer", "description": "Maximum estimated tokens per chunk."},
+    "chunk_count": {"type": "integer"},
+    "manifest": {
+      "type": "object",
+      "description": "The paths of the files in each chunk, by chunk ID.",
+      "additionalProperties": {"type": "array", "items": {"type": "string"}}
+    },
+    "chunks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "pattern": "^chunk-[0-9]{4,}$"},
+          "tokens": {"type": "integer", "description": "Estimated tokens of the content."},
+          "files": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "path": {"type": "string"},
+                "start_line": {"type": "integer"},
+                "end_line": {"type": "integer"},
+                "omitted": {"type": "boolean", "description": "Whether the content was left out by no-content-for."}
+              },
+              "required": ["path", "start_line", "end_line"],
+              "additionalProperties": false
+            }
+          },
+          "content": {"type": "string", "description": "Markdown sections of the chunk's files."}
+        },
+        "required": ["id", "tokens", "files", "content"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["repository", "generated", "chunk_tokens", "chunk_count", "manifest", "chunks"],
+  "additionalProperties": false
+}
+`
+
+// JSONSchema returns the JSON Schema of the output of a JSON-based format. For jsonl,
+// the schema describes a single line. The schemas are hand-written; tests validate real
+// output against them to keep them in sync with JSONDocument, JSONFile and ChunkDocument.
+func JSONSchema(format string) (string, error) {
+	switch format {
+	case config.FormatJSON:
+		return jsonDocumentSchema, nil
+	case config.FormatJSONL:
+		return jsonLineSchema()
+	case config.FormatLLMChunks:
+		return chunkDocumentSchema, nil
+	default:
+		return "", fmt.Errorf("%w: %q (expected %s, %s or %s)",
+			ErrNoJSONSchema, format, config.FormatJSON, config.FormatJSONL, config.FormatLLMChunks)
+	}
+}
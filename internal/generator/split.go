@@ -0,0 +1,265 @@
+package generator
+
+import (
+	"bufio"
+	"code2md/internal/gatherer"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrDirOutputCollision is returned when two top-level directories would be written to
+// the same document of a split-by-directory output.
+var ErrDirOutputCollision = errors.New("directory documents collide")
+
+// outputPart describes the part of a split output being written.
+type outputPart struct {
+	number  int      // Counting from one.
+	names   []string // File names of all parts, in order.
+	files   [][]string
+	omitted map[string]int // Files left out by MaxFilesPerDir, by directory, noted in this part.
+}
+
+// assignParts distributes the files, in order, over parts of at most limit bytes of
+// content. A file larger than limit gets a part of its own.
+func assignParts(files []gatherer.FileInfo, limit int64) [][]gatherer.FileInfo {
+	var (
+		parts [][]gatherer.FileInfo
+		size  int64
+	)
+
+	for _, file := range files {
+		if len(parts) == 0 || (size > 0 && size+file.Size > limit) {
+			parts = append(parts, nil)
+			size = 0
+		}
+
+		parts[len(parts)-1] = append(parts[len(parts)-1], file)
+		size += file.Size
+	}
+
+	return parts
+}
+
+// omissionsByPart assigns the omission count of each directory to the part holding the
+// directory's last kept file, so that every note is written once, after that file.
+func omissionsByPart(parts [][]gatherer.FileInfo, omitted map[string]int) []map[string]int {
+	lastPart := make(map[string]int, len(omitted))
+
+	for i, partFiles := range parts {
+		for _, file := range partFiles {
+			lastPart[path.Dir(file.Path)] = i
+		}
+	}
+
+	partOmitted := make([]map[string]int, len(parts))
+
+	for dir, count := range omitted {
+		i := lastPart[dir]
+		if partOmitted[i] == nil {
+			partOmitted[i] = make(map[string]int)
+		}
+
+		partOmitted[i][dir] = count
+	}
+
+	return partOmitted
+}
+
+// partFileName returns the name of the nth part of the output file, e.g. codebase-part2.md.
+func partFileName(outputFile string, n int) string {
+	return splitFileName(outputFile, fmt.Sprintf("part%d", n))
+}
+
+// IndexFileName returns the name of the summary written next to the parts of the output
+// file, e.g. codebase-index.md.
+func IndexFileName(outputFile string) string {
+	return splitFileName(outputFile, "index")
+}
+
+// SplitOutputGlobs returns patterns matching the parts and the index of the output file,
+// for excluding them from gathering. outputFile may itself be a pattern.
+func SplitOutputGlobs(outputFile string) []string {
+	return []string{splitFileName(outputFile, "part*"), IndexFileName(outputFile)}
+}
+
+// splitFileName returns the output file name with a suffix before its extension, which
+// defaults to .md.
+func splitFileName(outputFile, suffix string) string {
+	ext := filepath.Ext(outputFile)
+	if ext == "" {
+		ext = ".md"
+	}
+
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "-" + suffix + ext
+}
+
+// GenerateParts splits the files into parts of at most SplitSize bytes of content and
+// writes each part as its own markdown document, named after OutputFile with a -partN
+// suffix, plus an index with an -index suffix summarizing the split. Each part states its number and lists
+// the files of every part. MaxFilesPerDir is applied before splitting, so that it caps the
+// output as a whole. It returns the paths written, index last.
+func (mg *MarkdownGenerator) GenerateParts(files []gatherer.FileInfo, rootPath string) ([]string, error) {
+	files, omitted := mg.sampleFiles(files)
+	parts := assignParts(files, mg.config.SplitSize)
+	partOmitted := omissionsByPart(parts, omitted)
+
+	part := outputPart{names: make([]string, len(parts)), files: make([][]string, len(parts))}
+	paths := make([]string, 0, len(parts)+1)
+
+	for i, partFiles := range parts {
+		paths = append(paths, partFileName(mg.config.OutputFile, i+1))
+		part.names[i] = filepath.Base(paths[i])
+
+		part.files[i] = make([]string, len(partFiles))
+		for j, file := range partFiles {
+			part.files[i][j] = file.Path
+		}
+	}
+
+	for i, partFiles := range parts {
+		partGen := *mg
+		partGen.part = &outputPart{number: i + 1, names: part.names, files: part.files, omitted: partOmitted[i]}
+
+		if err := WriteFile(&partGen, paths[i], partFiles, rootPath); err != nil {
+			return nil, err
+		}
+	}
+
+	indexPath := IndexFileName(mg.config.OutputFile)
+	index := &indexGenerator{repoName: mg.config.RepoName, parts: parts, names: part.names}
+
+	if err := WriteFile(index, indexPath, nil, rootPath); err != nil {
+		return nil, err
+	}
+
+	return append(paths, indexPath), nil
+}
+
+// GenerateByDir partitions the files by their first path segment and writes each partition
+// as a complete markdown document named after the directory, e.g. cmd.md and internal.md,
+// in the directory given by DirOutputDir. Files in the root directory are written to
+// OutputFile itself. Directories whose names differ only in case are rejected, since their
+// documents would overwrite each other on case-insensitive file systems.
+// It returns the paths written, in directory order with the root files first.
+func (mg *MarkdownGenerator) GenerateByDir(files []gatherer.FileInfo, rootPath string) ([]string, error) {
+	outputDir := DirOutputDir(mg.config.OutputFile)
+	partitions := make(map[string][]gatherer.FileInfo)
+	folded := make(map[string]string)
+
+	var dirs []string
+
+	for _, file := range files {
+		dir := "."
+		if top, _, found := strings.Cut(file.Path, "/"); found {
+			dir = top
+		}
+
+		if _, seen := partitions[dir]; !seen {
+			if other, clash := folded[strings.ToLower(dir)]; clash {
+				return nil, fmt.Errorf("%w: %q and %q", ErrDirOutputCollision, other, dir)
+			}
+
+			folded[strings.ToLower(dir)] = dir
+			dirs = append(dirs, dir)
+		}
+
+		partitions[dir] = append(partitions[dir], file)
+	}
+
+	slices.Sort(dirs)
+
+	if len(dirs) > 1 || (len(dirs) == 1 && dirs[0] != ".") {
+		if err := os.MkdirAll(outputDir, 0o750); err != nil {
+			return nil, &GenerateError{Phase: "output", Path: outputDir, Err: err}
+		}
+	}
+
+	paths := make([]string, 0, len(dirs))
+
+	for _, dir := range dirs {
+		outputPath := mg.config.OutputFile
+		if dir != "." {
+			outputPath = filepath.Join(outputDir, dir+".md")
+		}
+
+		if err := WriteFile(mg, outputPath, partitions[dir], rootPath); err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, outputPath)
+	}
+
+	return paths, nil
+}
+
+// DirOutputDir returns the directory holding the per-directory documents of the output
+// file, e.g. codebase-dirs for codebase.md. outputFile may itself be a pattern.
+func DirOutputDir(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "-dirs"
+}
+
+// writePartNavigation states which part this is and lists the files of every part,
+// so that a reader of one part knows the structure of the whole output.
+func writePartNavigation(writer *bufio.Writer, part *outputPart) error {
+	total := len(part.names)
+	if _, err := fmt.Fprintf(writer, "**Part %d of %d**\n\n## Parts\n\n", part.number, total); err != nil {
+		return err
+	}
+
+	for i, name := range part.names {
+		label := fmt.Sprintf("[Part %d](%s)", i+1, name)
+		if i+1 == part.number {
+			label = fmt.Sprintf("**Part %d (this part)**", i+1)
+		}
+
+		if _, err := fmt.Fprintf(writer, "- %s: `%s`\n", label, strings.Join(part.files[i], "`, `")); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "\n")
+
+	return err
+}
+
+// indexGenerator renders the summary of a split output with the files and size of each part.
+type indexGenerator struct {
+	repoName string
+	parts    [][]gatherer.FileInfo
+	names    []string
+}
+
+// Generate writes the index; the files and root path are those of the parts instead.
+func (ig *indexGenerator) Generate(w io.Writer, _ []gatherer.FileInfo, _ string) error {
+	title := "Codebase Analysis"
+	if ig.repoName != "" {
+		title += ": " + ig.repoName
+	}
+
+	var index strings.Builder
+
+	fmt.Fprintf(&index, "# %s\n\nThe output is split into %d parts.\n\n", title, len(ig.parts))
+
+	for i, partFiles := range ig.parts {
+		fmt.Fprintf(&index, "## [Part %d](%s)\n\n**Files:** %d  \n**Total Size:** %s  \n\n",
+			i+1, ig.names[i], len(partFiles), FormatBytes(calculateTotalSize(partFiles)))
+
+		for _, file := range partFiles {
+			fmt.Fprintf(&index, "- `%s`\n", file.Path)
+		}
+
+		index.WriteString("\n")
+	}
+
+	if _, err := io.WriteString(w, index.String()); err != nil {
+		return &GenerateError{Phase: "index", Err: err}
+	}
+
+	return nil
+}
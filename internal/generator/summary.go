@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"bytes"
+	"code2md/internal/config"
+	"code2md/internal/gatherer"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// summaryEdgeLines is the number of lines kept at each end of a file summarized without a parser.
+const summaryEdgeLines = 10
+
+// contentMode is how much of a file's content is written to its section.
+type contentMode int
+
+const (
+	contentFull    contentMode = iota
+	contentOmitted             // Listed with "(content omitted)".
+	contentSummary             // Replaced by a structural summary.
+)
+
+// contentRules holds the patterns that select a content mode other than contentFull.
+type contentRules struct {
+	noContent gatherer.GlobSet
+	summarize gatherer.GlobSet
+}
+
+// newContentRules compiles the NoContentFor and Summarize patterns of cfg.
+func newContentRules(cfg *config.Config) (contentRules, error) {
+	noContent, err := gatherer.CompileGlobSet(cfg.NoContentFor)
+	if err != nil {
+		return contentRules{}, fmt.Errorf("invalid --no-content-for pattern: %w", err)
+	}
+
+	summarize, err := gatherer.CompileGlobSet(cfg.Summarize)
+	if err != nil {
+		return contentRules{}, fmt.Errorf("invalid --summarize pattern: %w", err)
+	}
+
+	return contentRules{noContent: noContent, summarize: summarize}, nil
+}
+
+// mode returns the content mode of the file at path. Omitting content wins over summarizing it.
+func (r contentRules) mode(path string) contentMode {
+	switch {
+	case r.noContent.Match(path):
+		return contentOmitted
+	case r.summarize.Match(path):
+		return contentSummary
+	default:
+		return contentFull
+	}
+}
+
+// summarizeContent returns a deterministic structural summary of a file. Go files are
+// reduced to their top-level function signatures and type names; other files, and Go
+// files that do not parse, keep their first and last summaryEdgeLines lines.
+func summarizeContent(path, content, lang string) string {
+	if lang == "go" {
+		if summary, ok := summarizeGo(path, content); ok {
+			return summary
+		}
+	}
+
+	return summarizeEdges(content)
+}
+
+// summarizeGo lists the top-level functions, with their signatures, and types of a Go file.
+func summarizeGo(path, content string) (string, bool) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+	if err != nil {
+		return "", false
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// Summary of a %d-line file: top-level declarations only.\n\npackage %s\n\n",
+		countLines(content), file.Name.Name)
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			signature := *decl
+			signature.Body, signature.Doc = nil, nil
+
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, &signature); err != nil {
+				return "", false
+			}
+
+			b.WriteString(buf.String() + "\n")
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok {
+					fmt.Fprintf(&b, "type %s %s\n", spec.Name.Name, typeKind(spec.Type))
+				}
+			}
+		}
+	}
+
+	return b.String(), true
+}
+
+// typeKind names the kind of a type expression, such as "struct" or "interface".
+func typeKind(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	case *ast.FuncType:
+		return "func"
+	case *ast.MapType:
+		return "map"
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			return "array"
+		}
+
+		return "slice"
+	case *ast.ChanType:
+		return "chan"
+	default:
+		return "..."
+	}
+}
+
+// summarizeEdges keeps the first and last summaryEdgeLines lines of content, with a note
+// in place of the lines in between. Short content is returned unchanged.
+func summarizeEdges(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) <= 2*summaryEdgeLines {
+		return content
+	}
+
+	head := strings.Join(lines[:summaryEdgeLines], "")
+	tail := strings.Join(lines[len(lines)-summaryEdgeLines:], "")
+	omitted := len(lines) - 2*summaryEdgeLines
+
+	return fmt.Sprintf("%s... (%d lines omitted) ...\n%s", head, omitted, tail)
+}
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"code2md/internal/gatherer"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the data passed to a user-supplied --template.
+type TemplateData struct {
+	Name       string
+	Repository string
+	Generated  time.Time
+	FileCount  int
+	TotalSize  int64
+	Files      []TemplateFile
+	Vars       map[string]string
+}
+
+// TemplateFile describes a single gathered file in TemplateData.
+type TemplateFile struct {
+	Path     string
+	Size     int64
+	Language string
+	Content  string
+}
+
+// parseTemplate parses the Go text/template at path. The helper functions
+// formatBytes and anchor are available to the template.
+func parseTemplate(path string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"formatBytes": FormatBytes,
+		"anchor":      sanitizeAnchor,
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// renderTemplate executes tmpl with the gathered files.
+func (mg *MarkdownGenerator) renderTemplate(w io.Writer, tmpl *template.Template, files []gatherer.FileInfo, rootPath string) error {
+	data := TemplateData{
+		Name:       mg.config.RepoName,
+		Repository: rootPath,
+		Generated:  time.Now(),
+		FileCount:  len(files),
+		TotalSize:  calculateTotalSize(files),
+		Files:      make([]TemplateFile, len(files)),
+		Vars:       mg.config.TemplateVars,
+	}
+
+	for i, file := range files {
+		lang := languageOf(file)
+		data.Files[i] = TemplateFile{
+			Path:     file.Path,
+			Size:     file.Size,
+			Language: lang,
+			Content:  mg.prepareContent(file.Path, file.Text(), lang),
+		}
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
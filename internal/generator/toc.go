@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"bufio"
+	"code2md/internal/gatherer"
+	"fmt"
+	"strings"
+)
+
+// writeTOCTable writes the table of contents as a markdown table with one row per file,
+// in output order.
+func writeTOCTable(writer *bufio.Writer, files []gatherer.FileInfo, anchors []string) error {
+	if _, err := fmt.Fprintf(writer, "| File | Size | Lines | Language |\n| --- | --- | --- | --- |\n"); err != nil {
+		return err
+	}
+
+	for i, file := range files {
+		if _, err := fmt.Fprintf(writer, "| [%s](#%s) | %s | %d | %s |\n",
+			escapeTableCell(file.Path), escapeTableCell(anchors[i]), FormatBytes(file.Size), countLines(file.Text()),
+			escapeTableCell(languageOf(file))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// escapeTableCell escapes the pipes that would otherwise end a markdown table cell.
+func escapeTableCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// countLines returns the number of lines in content; a final line without a newline counts.
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+
+	return lines
+}
+
+// TOCNode is a directory or file in the hierarchical table of contents.
+type TOCNode struct {
+	Name     string
+	IsDir    bool
+	Children []*TOCNode
+	File     *gatherer.FileInfo // Set for files only.
+}
+
+// buildTOCTree arranges the files into a directory tree, keeping children in file order.
+func buildTOCTree(files []gatherer.FileInfo) *TOCNode {
+	root := &TOCNode{IsDir: true}
+
+	for i := range files {
+		parts := strings.Split(files[i].Path, "/")
+
+		node := root
+		for _, dir := range parts[:len(parts)-1] {
+			node = node.childDir(dir)
+		}
+
+		node.Children = append(node.Children, &TOCNode{Name: parts[len(parts)-1], File: &files[i]})
+	}
+
+	return root
+}
+
+// childDir returns the child directory with the given name, creating it when missing.
+func (n *TOCNode) childDir(name string) *TOCNode {
+	for _, child := range n.Children {
+		if child.IsDir && child.Name == name {
+			return child
+		}
+	}
+
+	child := &TOCNode{Name: name, IsDir: true}
+	n.Children = append(n.Children, child)
+
+	return child
+}
+
+// writeTOCTree writes the children of node as a nested list at the given depth, linking
+// each file to its anchor. A directory holding a single file gets no level of its own;
+// the file is listed with the directory as prefix instead.
+func writeTOCTree(writer *bufio.Writer, node *TOCNode, anchors map[string]string, depth int, prefix string) error {
+	indent := strings.Repeat("  ", depth)
+
+	for _, child := range node.Children {
+		name := prefix + child.Name
+
+		switch {
+		case !child.IsDir:
+			if _, err := fmt.Fprintf(writer, "%s- [%s](#%s)\n", indent, name, anchors[child.File.Path]); err != nil {
+				return err
+			}
+		case len(child.Children) == 1 && !child.Children[0].IsDir:
+			if err := writeTOCTree(writer, child, anchors, depth, name+"/"); err != nil {
+				return err
+			}
+		default:
+			if _, err := fmt.Fprintf(writer, "%s- `%s/`\n", indent, name); err != nil {
+				return err
+			}
+
+			if err := writeTOCTree(writer, child, anchors, depth+1, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
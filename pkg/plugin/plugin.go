@@ -0,0 +1,36 @@
+// Package plugin defines what a code2md output format plugin implements. A plugin is a
+// Go plugin (built with -buildmode=plugin) that exports a variable named GeneratorSymbol
+// of type Generator and a function named NameSymbol returning the format name:
+//
+//	var Generator plugin.Generator = myGenerator{}
+//
+//	func PluginName() string { return "myformat" }
+//
+// The format is then selected with --format myformat and written to <output>.myformat.
+package plugin
+
+import (
+	"io"
+	"time"
+)
+
+// Names of the symbols that code2md looks up in a plugin.
+const (
+	GeneratorSymbol = "Generator"
+	NameSymbol      = "PluginName"
+)
+
+// File is a gathered file as passed to a plugin.
+type File struct {
+	Path     string // Relative to the scanned directory, with forward slashes.
+	Language string // Detected language, as used for markdown code fences.
+	Size     int64
+	ModTime  time.Time
+	Content  string
+	Omitted  bool // The file matches --no-content-for, so Content is empty.
+}
+
+// Generator renders the gathered files into the plugin's output format.
+type Generator interface {
+	Generate(w io.Writer, files []File, rootPath string) error
+}